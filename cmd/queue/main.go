@@ -0,0 +1,283 @@
+// Command queue runs the worker as an SQS consumer. Each message carries the
+// same JSON body as an HTTP check request. Messages are deleted only after
+// their results are stored, so a crashed or failed batch is redelivered
+// once its visibility timeout lapses, and poison messages end up in the
+// queue's dead-letter queue through its redrive policy.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	_ "monitor-workder/plugins"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/content"
+	"monitor-workder/pkg/heartbeat"
+	"monitor-workder/pkg/incident"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/shadow"
+	"monitor-workder/pkg/stats"
+	"monitor-workder/pkg/storage"
+	"monitor-workder/pkg/tracing"
+	"monitor-workder/pkg/worker"
+)
+
+type consumer struct {
+	client         *sqs.Client
+	queueURL       string
+	resultQueueURL string
+	visibility     time.Duration
+	checkRuns      *storage.CheckRuns
+	history        *stats.Store
+}
+
+// resultMessage is published to the result queue for every processed job.
+type resultMessage struct {
+	MessageID  string           `json:"messageId"`
+	CheckRunID string           `json:"checkRunId"`
+	Region     string           `json:"region"`
+	Results    []check.Result   `json:"results"`
+	Summary    stats.Invocation `json:"summary"`
+}
+
+func main() {
+	if err := godotenv.Load(".env"); err != nil {
+		log.Warn().Err(err).Msg("Error loading environment variables from .env")
+	}
+	if err := config.Load(); err != nil {
+		log.Fatal().Err(err).Msg("Unable to load config file")
+	}
+	logging.Configure()
+
+	queueURL := config.String("SQS_QUEUE_URL", "")
+	if queueURL == "" {
+		log.Fatal().Msg("SQS_QUEUE_URL is required")
+	}
+
+	db, dialect, err := storage.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to connect to database")
+	}
+	defer db.Close()
+
+	if err := storage.Configure(db, dialect); err != nil {
+		log.Fatal().Err(err).Msg("Unable to configure result sinks")
+	}
+	shadow.Configure(db, dialect)
+	heartbeat.Configure(db, dialect)
+	incident.Configure(db, dialect)
+	content.Configure(db, dialect)
+	if err := tracing.Configure(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Unable to configure tracing")
+	}
+	defer tracing.Shutdown(context.Background())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go config.Watch(ctx)
+
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to load AWS configuration")
+	}
+
+	c := &consumer{
+		client:         sqs.NewFromConfig(awsCfg),
+		queueURL:       queueURL,
+		resultQueueURL: config.String("SQS_RESULT_QUEUE_URL", ""),
+		visibility:     config.Duration("SQS_VISIBILITY_TIMEOUT", time.Minute),
+		checkRuns: &storage.CheckRuns{
+			DB:      db,
+			Dialect: dialect,
+			Window:  config.Duration("IDEMPOTENCY_WINDOW", 10*time.Minute),
+		},
+		history: &stats.Store{DB: db, Dialect: dialect},
+	}
+
+	log.Info().Str("queue", queueURL).Msg("Queue consumer started")
+	for ctx.Err() == nil {
+		out, err := c.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(c.queueURL),
+			MaxNumberOfMessages: int32(config.Int("SQS_MAX_MESSAGES", 10)),
+			WaitTimeSeconds:     20,
+			VisibilityTimeout:   int32(c.visibility.Seconds()),
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error().Err(err).Msg("Error receiving messages")
+				time.Sleep(5 * time.Second)
+			}
+			continue
+		}
+
+		// Messages already received are finished even after a shutdown
+		// signal, so they are not left invisible until their timeout.
+		var wg sync.WaitGroup
+		for _, msg := range out.Messages {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.handle(context.WithoutCancel(ctx), msg)
+			}()
+		}
+		wg.Wait()
+	}
+	log.Info().Msg("Queue consumer stopped")
+}
+
+func (c *consumer) handle(ctx context.Context, msg types.Message) {
+	messageID := aws.ToString(msg.MessageId)
+	ctx, span := tracing.Tracer.Start(ctx, "queue.message", trace.WithAttributes(
+		attribute.String("messaging.message.id", messageID),
+	))
+	defer span.End()
+	ctx = logging.WithRequestID(ctx, messageID)
+	logger := logging.From(ctx)
+
+	stopHeartbeat := c.extendVisibility(ctx, msg.ReceiptHandle)
+	defer stopHeartbeat()
+
+	req, err := worker.ParseRequest([]byte(aws.ToString(msg.Body)))
+	if err != nil {
+		logger.Error().Err(err).Msg("Invalid message, leaving for redrive")
+		return
+	}
+
+	checkRunID := req.CheckRunID
+	if checkRunID == "" {
+		checkRunID = messageID
+	}
+
+	_, claimed, err := c.checkRuns.Claim(ctx, checkRunID)
+	if errors.Is(err, storage.ErrCheckRunInProgress) {
+		logger.Info().Msg("Check run already in progress elsewhere")
+		return
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error claiming check run")
+		return
+	}
+	if !claimed {
+		logger.Info().Msg("Duplicate delivery of completed check run")
+		c.delete(ctx, msg.ReceiptHandle)
+		return
+	}
+
+	// Until the response is stored, any early return leaves the message
+	// for redelivery, so the claim is dropped to let that delivery run.
+	stored := false
+	defer func() {
+		if stored {
+			return
+		}
+		if err := c.checkRuns.Release(ctx, checkRunID); err != nil {
+			logger.Error().Err(err).Msg("Error releasing check run")
+		}
+	}()
+
+	if err := req.Wait(ctx); err != nil {
+		logger.Error().Err(err).Msg("Interrupted waiting for executeAt")
+		return
+	}
+	// Dry runs still claim the check run, so a redelivered message is not
+	// checked twice, but store no results.
+	if req.DryRun {
+		ctx = storage.WithDryRun(ctx)
+	}
+	if req.Guarded {
+		ctx = check.WithGuard(ctx)
+	}
+	startedAt := time.Now()
+	results := worker.Run(ctx, req.Region, checkRunID, req.Urls)
+	if err := storage.Flush(ctx); err != nil {
+		logger.Error().Err(err).Msg("Error flushing results, leaving for redelivery")
+		return
+	}
+
+	summary := stats.SummarizeInvocation(req.Region, startedAt, results)
+	if !req.DryRun {
+		if err := c.history.RecordInvocation(ctx, summary); err != nil {
+			logger.Error().Err(err).Msg("Error recording invocation summary")
+		}
+	}
+
+	body, err := json.Marshal(resultMessage{
+		MessageID:  messageID,
+		CheckRunID: checkRunID,
+		Region:     req.Region,
+		Results:    results,
+		Summary:    summary,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error encoding results")
+		return
+	}
+
+	if c.resultQueueURL != "" {
+		if _, err := c.client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    aws.String(c.resultQueueURL),
+			MessageBody: aws.String(string(body)),
+		}); err != nil {
+			logger.Error().Err(err).Msg("Error publishing results")
+			return
+		}
+	}
+
+	if err := c.checkRuns.Store(ctx, checkRunID, body); err != nil {
+		logger.Error().Err(err).Msg("Error storing check run")
+	} else {
+		stored = true
+	}
+	c.delete(ctx, msg.ReceiptHandle)
+}
+
+// extendVisibility keeps msg hidden from other consumers while it is being
+// processed by pushing its visibility timeout out at half-timeout intervals.
+func (c *consumer) extendVisibility(ctx context.Context, receiptHandle *string) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		ticker := time.NewTicker(c.visibility / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+					QueueUrl:          aws.String(c.queueURL),
+					ReceiptHandle:     receiptHandle,
+					VisibilityTimeout: int32(c.visibility.Seconds()),
+				}); err != nil && ctx.Err() == nil {
+					log.Error().Err(err).Msg("Error extending message visibility")
+				}
+			}
+		}
+	}()
+	return cancel
+}
+
+func (c *consumer) delete(ctx context.Context, receiptHandle *string) {
+	if _, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueURL),
+		ReceiptHandle: receiptHandle,
+	}); err != nil {
+		log.Error().Err(err).Msg("Error deleting message")
+	}
+}
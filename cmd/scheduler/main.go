@@ -0,0 +1,184 @@
+// Command scheduler runs the worker without an external scheduler: it
+// periodically claims due monitors from the websites table, checks them and
+// stores the results.
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+
+	_ "monitor-workder/plugins"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/content"
+	"monitor-workder/pkg/discovery"
+	"monitor-workder/pkg/heartbeat"
+	"monitor-workder/pkg/incident"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/rollup"
+	"monitor-workder/pkg/shadow"
+	"monitor-workder/pkg/stats"
+	"monitor-workder/pkg/storage"
+	"monitor-workder/pkg/tracing"
+	"monitor-workder/pkg/worker"
+)
+
+func main() {
+	if err := godotenv.Load(".env"); err != nil {
+		log.Warn().Err(err).Msg("Error loading environment variables from .env")
+	}
+	if err := config.Load(); err != nil {
+		log.Fatal().Err(err).Msg("Unable to load config file")
+	}
+	logging.Configure()
+
+	db, dialect, err := storage.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to connect to database")
+	}
+	defer db.Close()
+
+	if dialect != storage.Postgres {
+		log.Fatal().Str("driver", string(dialect)).Msg("The scheduler requires a Postgres database")
+	}
+
+	if err := storage.Configure(db, dialect); err != nil {
+		log.Fatal().Err(err).Msg("Unable to configure result sinks")
+	}
+	shadow.Configure(db, dialect)
+	heartbeat.Configure(db, dialect)
+	incident.Configure(db, dialect)
+	content.Configure(db, dialect)
+	if err := tracing.Configure(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Unable to configure tracing")
+	}
+	defer tracing.Shutdown(context.Background())
+	discovery.Register("postgres", &discovery.Postgres{
+		DB:    db,
+		Limit: config.Int("SCHEDULER_BATCH_SIZE", 50),
+	})
+
+	source := config.String("SCHEDULER_DISCOVERY", "postgres")
+	discoverer, ok := discovery.Lookup(source)
+	if !ok {
+		log.Fatal().Str("discovery", source).Msg("Unknown discovery source")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go config.Watch(ctx)
+
+	region := config.String("SCHEDULER_REGION", "")
+	tick := time.NewTicker(config.Duration("SCHEDULER_TICK", 10*time.Second))
+	defer tick.Stop()
+
+	if every := config.Duration("ROLLUP_EVERY", 0); every > 0 {
+		go runRollups(ctx, &rollup.Job{DB: db, Dialect: dialect}, every)
+	}
+
+	history := &stats.Store{DB: db, Dialect: dialect}
+
+	log.Info().Str("discovery", source).Str("region", region).Msg("Scheduler started")
+	for {
+		runDue(ctx, discoverer, history, region)
+
+		select {
+		case <-ctx.Done():
+			if err := storage.Flush(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Error flushing results")
+			}
+			log.Info().Msg("Scheduler stopped")
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// runRollups enforces result retention every interval until ctx is done,
+// repeating immediately while a run leaves rows behind.
+func runRollups(ctx context.Context, job *rollup.Job, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for ctx.Err() == nil {
+			report, err := job.Run(ctx, rollup.Cutoff())
+			if errors.Is(err, rollup.ErrRunning) {
+				log.Info().Msg("Skipping rollup, another run holds the lease")
+				break
+			}
+			if err != nil {
+				log.Error().Err(err).Msg("Error rolling up results")
+				break
+			}
+			log.Info().Int("rows", report.RowsMoved).Int("buckets", report.Buckets).Msg("Rolled up results")
+			if !report.Remaining {
+				break
+			}
+		}
+	}
+}
+
+func runDue(ctx context.Context, discoverer discovery.Discoverer, history *stats.Store, region string) {
+	ctx, span := tracing.Tracer.Start(ctx, "scheduler.run")
+	defer span.End()
+	ctx = logging.WithRequestID(ctx, uuid.NewString())
+	logger := logging.From(ctx)
+
+	targets, err := discoverer.Discover(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error discovering due monitors")
+		return
+	}
+
+	valid := targets[:0]
+	for _, target := range targets {
+		if err := check.Validate(target); err != nil {
+			logger.Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Skipping invalid monitor")
+			continue
+		}
+		valid = append(valid, target)
+	}
+
+	if len(valid) == 0 {
+		return
+	}
+
+	startedAt := time.Now()
+	results := worker.Run(ctx, region, "", valid)
+	if err := history.RecordInvocation(ctx, stats.SummarizeInvocation(region, startedAt, results)); err != nil {
+		logger.Error().Err(err).Msg("Error recording invocation summary")
+	}
+
+	// Honour Retry-After from throttled targets unless disabled.
+	deferrer, ok := discoverer.(interface {
+		Defer(ctx context.Context, websiteID uuid.UUID, until time.Time) error
+	})
+	if !ok || config.String("RESPECT_RETRY_AFTER", "true") != "true" {
+		return
+	}
+	maxDelay := config.Duration("RETRY_AFTER_MAX", time.Hour)
+	for _, result := range results {
+		if result.RetryAfter <= 0 {
+			continue
+		}
+		delay := min(time.Duration(result.RetryAfter)*time.Second, maxDelay)
+		if err := deferrer.Defer(ctx, result.WebsiteID, time.Now().Add(delay)); err != nil {
+			logger.Error().Err(err).Str("websiteId", result.WebsiteID.String()).Msg("Error deferring throttled monitor")
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Command server runs the worker's HTTP API as a long-lived process, for
+// container deployments. On SIGINT or SIGTERM it stops accepting
+// connections, waits up to SHUTDOWN_TIMEOUT for in-flight requests (and
+// so their checks) to finish, then flushes buffered results and closes
+// the database. SIGHUP reloads CONFIG_FILE.
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/server"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go config.Watch(ctx)
+
+	srv := &http.Server{
+		Addr:              config.String("LISTEN_ADDR", ":8080"),
+		Handler:           http.HandlerFunc(server.Handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+	log.Info().Str("addr", srv.Addr).Msg("Server started")
+
+	select {
+	case err := <-errs:
+		log.Fatal().Err(err).Msg("Server failed")
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Info().Msg("Shutting down, waiting for in-flight requests")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), config.Duration("SHUTDOWN_TIMEOUT", 30*time.Second))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("In-flight requests did not finish in time")
+	}
+	if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Error().Err(err).Msg("Server failed")
+	}
+	if err := server.Close(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Error flushing results")
+	}
+	log.Info().Msg("Server stopped")
+}
@@ -0,0 +1,9 @@
+-- Idempotency keys for check dispatches. A row is claimed before the checks
+-- run and its response is filled in once they finish.
+CREATE TABLE IF NOT EXISTS check_runs (
+    id         text PRIMARY KEY,
+    response   jsonb,
+    created_at timestamptz NOT NULL DEFAULT now()
+);
+
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS check_run_id text;
@@ -0,0 +1,7 @@
+-- Per-site scheduling state for the self-scheduling worker (cmd/scheduler).
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS check_interval integer NOT NULL DEFAULT 60;
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS next_check_at timestamptz;
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS check_type text;
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS script text;
+
+CREATE INDEX IF NOT EXISTS websites_next_check_at_idx ON websites (next_check_at);
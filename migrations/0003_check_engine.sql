@@ -0,0 +1,3 @@
+-- Records which check engine produced each result so canary rollouts can
+-- be compared.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS engine text;
@@ -0,0 +1,15 @@
+-- Disagreements between official and shadow checker implementations.
+CREATE TABLE IF NOT EXISTS check_discrepancies (
+    id                      bigserial PRIMARY KEY,
+    website_id              uuid        NOT NULL,
+    check_type              text        NOT NULL,
+    official_engine         text,
+    official_status         text        NOT NULL,
+    official_status_code    integer     NOT NULL,
+    official_response_time  bigint      NOT NULL,
+    candidate_engine        text,
+    candidate_status        text        NOT NULL,
+    candidate_status_code   integer     NOT NULL,
+    candidate_response_time bigint      NOT NULL,
+    created_at              timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- checked_at is the worker's wall clock when the check started; created_at
+-- is the database's clock when the row was written. clock_skew_ms is the
+-- worker's measured offset from the database clock at write time.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS created_at timestamptz NOT NULL DEFAULT now();
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS checked_at timestamptz;
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS clock_skew_ms integer;
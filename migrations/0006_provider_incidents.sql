@@ -0,0 +1,2 @@
+-- Comma-separated providers that had a declared incident when the check ran.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS provider_incidents text;
@@ -0,0 +1,4 @@
+-- Size (bytes actually read), content type and SHA-256 of the response body.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS content_length bigint NOT NULL DEFAULT 0;
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS content_type text;
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS body_sha256 text;
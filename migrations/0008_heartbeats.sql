@@ -0,0 +1,5 @@
+-- Last ping received from each push-based (heartbeat) monitor.
+CREATE TABLE IF NOT EXISTS heartbeats (
+    monitor_id   uuid PRIMARY KEY,
+    last_ping_at timestamptz NOT NULL
+);
@@ -0,0 +1,7 @@
+-- Grouping and pause state used by the admin bulk operations API.
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS tags text[] NOT NULL DEFAULT '{}';
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS template text;
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS paused boolean NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS websites_tags_idx ON websites USING gin (tags);
+CREATE INDEX IF NOT EXISTS websites_template_idx ON websites (template);
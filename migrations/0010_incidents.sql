@@ -0,0 +1,18 @@
+-- Incident lifecycle per website and region, maintained by pkg/incident.
+-- A row with opened_at NULL is a failure streak still below the threshold.
+CREATE TABLE IF NOT EXISTS incidents (
+    id          bigserial PRIMARY KEY,
+    website_id  uuid        NOT NULL,
+    region      text        NOT NULL DEFAULT '',
+    url         text,
+    started_at  timestamptz NOT NULL,
+    opened_at   timestamptz,
+    resolved_at timestamptz,
+    downtime_ms bigint,
+    failures    integer     NOT NULL,
+    status      text,
+    status_code integer
+);
+
+CREATE INDEX IF NOT EXISTS incidents_active_idx ON incidents (website_id, region) WHERE resolved_at IS NULL;
+CREATE INDEX IF NOT EXISTS incidents_started_at_idx ON incidents (started_at);
@@ -0,0 +1,3 @@
+-- Re-checks before a failure is reported, and whether it was confirmed.
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS confirmations integer NOT NULL DEFAULT 0;
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS confirmed boolean;
@@ -0,0 +1,20 @@
+-- Region of each raw result, and hourly/daily aggregates of raw results
+-- rolled up and deleted by the retention job (pkg/rollup).
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS region text;
+
+CREATE TABLE IF NOT EXISTS uptime_rollups (
+    website_id        uuid        NOT NULL,
+    region            text        NOT NULL DEFAULT '',
+    granularity       text        NOT NULL,
+    bucket_start      timestamptz NOT NULL,
+    checks            integer     NOT NULL,
+    up_checks         integer     NOT NULL,
+    down_checks       integer     NOT NULL,
+    throttled_checks  integer     NOT NULL,
+    latency_count     integer     NOT NULL,
+    latency_sum       bigint      NOT NULL,
+    uptime_pct        double precision,
+    avg_response_time double precision,
+    p95_response_time bigint,
+    PRIMARY KEY (website_id, region, granularity, bucket_start)
+);
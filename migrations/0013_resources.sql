@@ -0,0 +1,13 @@
+-- Versioned configuration documents served by the /v1/<kind> resource API,
+-- and the version used as the ETag of websites.
+CREATE TABLE IF NOT EXISTS resources (
+    kind       text        NOT NULL,
+    id         uuid        NOT NULL,
+    spec       jsonb       NOT NULL,
+    version    integer     NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (kind, id)
+);
+
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS version integer NOT NULL DEFAULT 1;
@@ -0,0 +1,13 @@
+-- Per-tenant signing keys for pkg/auth, and the tenant each website belongs
+-- to. Websites without a tenant can only be used with keys from HMAC_KEYS.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         text        PRIMARY KEY,
+    tenant_id  uuid        NOT NULL,
+    secret     text        NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    revoked_at timestamptz
+);
+
+ALTER TABLE websites ADD COLUMN IF NOT EXISTS tenant_id uuid;
+
+CREATE INDEX IF NOT EXISTS websites_tenant_id_idx ON websites (tenant_id);
@@ -0,0 +1,19 @@
+-- One row per batch of checks when INVOCATION_SUMMARIES is enabled, written
+-- by pkg/stats. Latency columns are NULL when no check got a response.
+CREATE TABLE IF NOT EXISTS invocation_summaries (
+    id                bigserial PRIMARY KEY,
+    region            text        NOT NULL DEFAULT '',
+    checked_at        timestamptz NOT NULL,
+    checks            integer     NOT NULL,
+    up_checks         integer     NOT NULL,
+    degraded_checks   integer     NOT NULL,
+    down_checks       integer     NOT NULL,
+    throttled_checks  integer     NOT NULL,
+    min_response_time bigint,
+    avg_response_time bigint,
+    p50_response_time bigint,
+    p95_response_time bigint,
+    max_response_time bigint
+);
+
+CREATE INDEX IF NOT EXISTS invocation_summaries_region_checked_at_idx ON invocation_summaries (region, checked_at);
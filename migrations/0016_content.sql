@@ -0,0 +1,11 @@
+-- Normalized body hash per check and whether it changed beyond the
+-- target's tolerance, plus the per-website baseline kept by pkg/content.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS content_sha256 text;
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS content_changed boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS content_baselines (
+    website_id  uuid PRIMARY KEY,
+    body_sha256 text        NOT NULL,
+    normalized  text        NOT NULL,
+    updated_at  timestamptz NOT NULL
+);
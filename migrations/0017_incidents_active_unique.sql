@@ -0,0 +1,8 @@
+-- At most one active incident per website and region. Concurrent failures
+-- could previously open duplicates; the newer ones are dropped first.
+DELETE FROM incidents a USING incidents b
+WHERE a.resolved_at IS NULL AND b.resolved_at IS NULL
+  AND a.website_id = b.website_id AND a.region = b.region AND a.id > b.id;
+
+DROP INDEX IF EXISTS incidents_active_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS incidents_active_idx ON incidents (website_id, region) WHERE resolved_at IS NULL;
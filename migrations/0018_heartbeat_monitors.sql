@@ -0,0 +1,7 @@
+-- Heartbeat monitors that pings are accepted for. A monitor is registered
+-- by its first heartbeat check, so random IDs posted to the unauthenticated
+-- ping endpoint are rejected instead of growing heartbeats.
+CREATE TABLE IF NOT EXISTS heartbeat_monitors (
+    monitor_id    uuid PRIMARY KEY,
+    registered_at timestamptz NOT NULL
+);
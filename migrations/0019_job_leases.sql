@@ -0,0 +1,7 @@
+-- Leases for jobs of which only one may run at a time, like the rollup
+-- (pkg/rollup). A lease left behind by a crashed run lapses at expires_at.
+CREATE TABLE IF NOT EXISTS job_leases (
+    name       text PRIMARY KEY,
+    holder     text        NOT NULL,
+    expires_at timestamptz NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Why a check failed, one of pkg/outcome's reasons, for pkg/stats to
+-- classify failures by.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS failure_reason text;
@@ -0,0 +1,7 @@
+-- Per-tenant settings written by the control plane, like api_keys.
+-- timezone is the IANA zone the tenant's daily rollups (pkg/rollup) start
+-- at midnight in.
+CREATE TABLE IF NOT EXISTS tenant_settings (
+    tenant_id uuid PRIMARY KEY,
+    timezone  text NOT NULL
+);
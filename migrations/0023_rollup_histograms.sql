@@ -0,0 +1,3 @@
+-- Latency histogram of each rollup bucket, so pkg/rollup can recompute the
+-- p95 when late-arriving results are merged into it.
+ALTER TABLE uptime_rollups ADD COLUMN IF NOT EXISTS latency_histogram text;
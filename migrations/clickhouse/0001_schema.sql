@@ -0,0 +1,13 @@
+-- Results table for RESULT_SINKS=clickhouse.
+CREATE TABLE IF NOT EXISTS uptime_checks (
+    website_id    UUID,
+    status        LowCardinality(String),
+    response_time Int64,
+    status_code   Int32,
+    check_run_id  String,
+    engine        LowCardinality(String),
+    created_at    DateTime64(3, 'UTC')
+)
+ENGINE = MergeTree
+PARTITION BY toYYYYMM(created_at)
+ORDER BY (website_id, created_at);
@@ -0,0 +1,3 @@
+-- created_at holds the worker's check time; recorded_at is ClickHouse's own
+-- clock when the batch was inserted.
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS recorded_at DateTime64(3, 'UTC') DEFAULT now64(3);
@@ -0,0 +1,4 @@
+ALTER TABLE uptime_checks
+    ADD COLUMN IF NOT EXISTS content_length Int64,
+    ADD COLUMN IF NOT EXISTS content_type LowCardinality(String),
+    ADD COLUMN IF NOT EXISTS body_sha256 String;
@@ -0,0 +1 @@
+ALTER TABLE uptime_checks ADD COLUMN IF NOT EXISTS confirmed Nullable(Bool);
@@ -0,0 +1,3 @@
+ALTER TABLE uptime_checks
+    ADD COLUMN IF NOT EXISTS content_sha256 String,
+    ADD COLUMN IF NOT EXISTS content_changed Bool DEFAULT false;
@@ -0,0 +1,17 @@
+-- Schema for self-hosted workers running with DB_DRIVER=mysql.
+CREATE TABLE IF NOT EXISTS uptime_checks (
+    id            bigint AUTO_INCREMENT PRIMARY KEY,
+    website_id    char(36)    NOT NULL,
+    status        varchar(16) NOT NULL,
+    response_time bigint      NOT NULL,
+    status_code   int         NOT NULL,
+    check_run_id  varchar(255),
+    created_at    timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    INDEX uptime_checks_website_id_created_at_idx (website_id, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS check_runs (
+    id         varchar(255) PRIMARY KEY,
+    response   json,
+    created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS check_discrepancies (
+    id                      bigint AUTO_INCREMENT PRIMARY KEY,
+    website_id              char(36)    NOT NULL,
+    check_type              varchar(32) NOT NULL,
+    official_engine         varchar(16),
+    official_status         varchar(16) NOT NULL,
+    official_status_code    int         NOT NULL,
+    official_response_time  bigint      NOT NULL,
+    candidate_engine        varchar(16),
+    candidate_status        varchar(16) NOT NULL,
+    candidate_status_code   int         NOT NULL,
+    candidate_response_time bigint      NOT NULL,
+    created_at              timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE uptime_checks ADD COLUMN checked_at timestamp(3) NULL;
+ALTER TABLE uptime_checks ADD COLUMN clock_skew_ms int;
@@ -0,0 +1,3 @@
+ALTER TABLE uptime_checks ADD COLUMN content_length bigint NOT NULL DEFAULT 0;
+ALTER TABLE uptime_checks ADD COLUMN content_type varchar(255);
+ALTER TABLE uptime_checks ADD COLUMN body_sha256 char(64);
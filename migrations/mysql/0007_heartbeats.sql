@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS heartbeats (
+    monitor_id   char(36) PRIMARY KEY,
+    last_ping_at timestamp(3) NOT NULL
+);
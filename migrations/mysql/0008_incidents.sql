@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS incidents (
+    id          bigint AUTO_INCREMENT PRIMARY KEY,
+    website_id  char(36)     NOT NULL,
+    region      varchar(64)  NOT NULL DEFAULT '',
+    url         text,
+    started_at  timestamp(3) NOT NULL,
+    opened_at   timestamp(3) NULL,
+    resolved_at timestamp(3) NULL,
+    downtime_ms bigint,
+    failures    int          NOT NULL,
+    status      varchar(16),
+    status_code int,
+    INDEX incidents_website_id_region_idx (website_id, region, resolved_at),
+    INDEX incidents_started_at_idx (started_at)
+);
@@ -0,0 +1,18 @@
+ALTER TABLE uptime_checks ADD COLUMN region varchar(64);
+
+CREATE TABLE IF NOT EXISTS uptime_rollups (
+    website_id        char(36)     NOT NULL,
+    region            varchar(64)  NOT NULL DEFAULT '',
+    granularity       varchar(8)   NOT NULL,
+    bucket_start      timestamp(3) NOT NULL,
+    checks            int          NOT NULL,
+    up_checks         int          NOT NULL,
+    down_checks       int          NOT NULL,
+    throttled_checks  int          NOT NULL,
+    latency_count     int          NOT NULL,
+    latency_sum       bigint       NOT NULL,
+    uptime_pct        double,
+    avg_response_time double,
+    p95_response_time bigint,
+    PRIMARY KEY (website_id, region, granularity, bucket_start)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS resources (
+    kind       varchar(64)  NOT NULL,
+    id         char(36)     NOT NULL,
+    spec       json         NOT NULL,
+    version    int          NOT NULL,
+    created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    updated_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    PRIMARY KEY (kind, id)
+);
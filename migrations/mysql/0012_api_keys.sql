@@ -0,0 +1,15 @@
+-- Self-hosted workers have no websites table of their own, so this one
+-- only records which tenant owns each website ID.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         varchar(64)  PRIMARY KEY,
+    tenant_id  char(36)     NOT NULL,
+    secret     varchar(255) NOT NULL,
+    created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    revoked_at timestamp(3) NULL
+);
+
+CREATE TABLE IF NOT EXISTS websites (
+    id        char(36) PRIMARY KEY,
+    tenant_id char(36),
+    INDEX websites_tenant_id_idx (tenant_id)
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS invocation_summaries (
+    id                bigint AUTO_INCREMENT PRIMARY KEY,
+    region            varchar(64)  NOT NULL DEFAULT '',
+    checked_at        timestamp(3) NOT NULL,
+    checks            int          NOT NULL,
+    up_checks         int          NOT NULL,
+    degraded_checks   int          NOT NULL,
+    down_checks       int          NOT NULL,
+    throttled_checks  int          NOT NULL,
+    min_response_time bigint,
+    avg_response_time bigint,
+    p50_response_time bigint,
+    p95_response_time bigint,
+    max_response_time bigint,
+    INDEX invocation_summaries_region_checked_at_idx (region, checked_at)
+);
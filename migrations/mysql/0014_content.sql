@@ -0,0 +1,9 @@
+ALTER TABLE uptime_checks ADD COLUMN content_sha256 char(64);
+ALTER TABLE uptime_checks ADD COLUMN content_changed boolean NOT NULL DEFAULT false;
+
+CREATE TABLE IF NOT EXISTS content_baselines (
+    website_id  char(36) PRIMARY KEY,
+    body_sha256 char(64)     NOT NULL,
+    normalized  mediumtext   NOT NULL,
+    updated_at  timestamp(3) NOT NULL
+);
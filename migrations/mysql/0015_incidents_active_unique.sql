@@ -0,0 +1,10 @@
+-- MySQL has no partial indexes, so uniqueness of active incidents is
+-- enforced on a generated column that is NULL once an incident resolves.
+DELETE a FROM incidents a JOIN incidents b
+  ON a.website_id = b.website_id AND a.region = b.region AND a.id > b.id
+WHERE a.resolved_at IS NULL AND b.resolved_at IS NULL;
+
+ALTER TABLE incidents
+    ADD COLUMN active_key varchar(101)
+        GENERATED ALWAYS AS (CASE WHEN resolved_at IS NULL THEN CONCAT(website_id, '/', region) END) STORED,
+    ADD UNIQUE INDEX incidents_active_idx (active_key);
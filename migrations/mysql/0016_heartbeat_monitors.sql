@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS heartbeat_monitors (
+    monitor_id    char(36) PRIMARY KEY,
+    registered_at timestamp(3) NOT NULL
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS job_leases (
+    name       varchar(64)  PRIMARY KEY,
+    holder     char(36)     NOT NULL,
+    expires_at timestamp(3) NOT NULL
+);
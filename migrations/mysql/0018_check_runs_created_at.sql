@@ -0,0 +1,2 @@
+-- Expired idempotency keys are swept by created_at (storage.CheckRuns.Claim).
+ALTER TABLE check_runs ADD INDEX check_runs_created_at_idx (created_at);
@@ -0,0 +1 @@
+ALTER TABLE uptime_checks ADD COLUMN failure_reason varchar(64);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS tenant_settings (
+    tenant_id char(36)    PRIMARY KEY,
+    timezone  varchar(64) NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE uptime_rollups ADD COLUMN latency_histogram text;
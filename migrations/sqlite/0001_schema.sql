@@ -0,0 +1,18 @@
+-- Schema for self-hosted workers running with DB_DRIVER=sqlite.
+CREATE TABLE IF NOT EXISTS uptime_checks (
+    id            integer PRIMARY KEY AUTOINCREMENT,
+    website_id    text    NOT NULL,
+    status        text    NOT NULL,
+    response_time integer NOT NULL,
+    status_code   integer NOT NULL,
+    check_run_id  text,
+    created_at    text    NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
+
+CREATE INDEX IF NOT EXISTS uptime_checks_website_id_created_at_idx ON uptime_checks (website_id, created_at);
+
+CREATE TABLE IF NOT EXISTS check_runs (
+    id         text PRIMARY KEY,
+    response   text,
+    created_at text NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
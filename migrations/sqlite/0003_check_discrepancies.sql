@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS check_discrepancies (
+    id                      integer PRIMARY KEY AUTOINCREMENT,
+    website_id              text    NOT NULL,
+    check_type              text    NOT NULL,
+    official_engine         text,
+    official_status         text    NOT NULL,
+    official_status_code    integer NOT NULL,
+    official_response_time  integer NOT NULL,
+    candidate_engine        text,
+    candidate_status        text    NOT NULL,
+    candidate_status_code   integer NOT NULL,
+    candidate_response_time integer NOT NULL,
+    created_at              text    NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now'))
+);
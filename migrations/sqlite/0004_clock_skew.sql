@@ -0,0 +1,2 @@
+ALTER TABLE uptime_checks ADD COLUMN checked_at text;
+ALTER TABLE uptime_checks ADD COLUMN clock_skew_ms integer;
@@ -0,0 +1,3 @@
+ALTER TABLE uptime_checks ADD COLUMN content_length integer NOT NULL DEFAULT 0;
+ALTER TABLE uptime_checks ADD COLUMN content_type text;
+ALTER TABLE uptime_checks ADD COLUMN body_sha256 text;
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS heartbeats (
+    monitor_id   text PRIMARY KEY,
+    last_ping_at text NOT NULL
+);
@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS incidents (
+    id          integer PRIMARY KEY AUTOINCREMENT,
+    website_id  text    NOT NULL,
+    region      text    NOT NULL DEFAULT '',
+    url         text,
+    started_at  text    NOT NULL,
+    opened_at   text,
+    resolved_at text,
+    downtime_ms integer,
+    failures    integer NOT NULL,
+    status      text,
+    status_code integer
+);
+
+CREATE INDEX IF NOT EXISTS incidents_website_id_region_idx ON incidents (website_id, region, resolved_at);
+CREATE INDEX IF NOT EXISTS incidents_started_at_idx ON incidents (started_at);
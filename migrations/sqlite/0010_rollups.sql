@@ -0,0 +1,18 @@
+ALTER TABLE uptime_checks ADD COLUMN region text;
+
+CREATE TABLE IF NOT EXISTS uptime_rollups (
+    website_id        text    NOT NULL,
+    region            text    NOT NULL DEFAULT '',
+    granularity       text    NOT NULL,
+    bucket_start      text    NOT NULL,
+    checks            integer NOT NULL,
+    up_checks         integer NOT NULL,
+    down_checks       integer NOT NULL,
+    throttled_checks  integer NOT NULL,
+    latency_count     integer NOT NULL,
+    latency_sum       integer NOT NULL,
+    uptime_pct        real,
+    avg_response_time real,
+    p95_response_time integer,
+    PRIMARY KEY (website_id, region, granularity, bucket_start)
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS resources (
+    kind       text    NOT NULL,
+    id         text    NOT NULL,
+    spec       text    NOT NULL,
+    version    integer NOT NULL,
+    created_at text    NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    updated_at text    NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    PRIMARY KEY (kind, id)
+);
@@ -0,0 +1,16 @@
+-- Self-hosted workers have no websites table of their own, so this one
+-- only records which tenant owns each website ID.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id         text PRIMARY KEY,
+    tenant_id  text NOT NULL,
+    secret     text NOT NULL,
+    created_at text NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ', 'now')),
+    revoked_at text
+);
+
+CREATE TABLE IF NOT EXISTS websites (
+    id        text PRIMARY KEY,
+    tenant_id text
+);
+
+CREATE INDEX IF NOT EXISTS websites_tenant_id_idx ON websites (tenant_id);
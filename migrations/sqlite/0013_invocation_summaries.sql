@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS invocation_summaries (
+    id                integer PRIMARY KEY AUTOINCREMENT,
+    region            text    NOT NULL DEFAULT '',
+    checked_at        text    NOT NULL,
+    checks            integer NOT NULL,
+    up_checks         integer NOT NULL,
+    degraded_checks   integer NOT NULL,
+    down_checks       integer NOT NULL,
+    throttled_checks  integer NOT NULL,
+    min_response_time integer,
+    avg_response_time integer,
+    p50_response_time integer,
+    p95_response_time integer,
+    max_response_time integer
+);
+
+CREATE INDEX IF NOT EXISTS invocation_summaries_region_checked_at_idx ON invocation_summaries (region, checked_at);
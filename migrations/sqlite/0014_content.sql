@@ -0,0 +1,9 @@
+ALTER TABLE uptime_checks ADD COLUMN content_sha256 text;
+ALTER TABLE uptime_checks ADD COLUMN content_changed integer NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS content_baselines (
+    website_id  text PRIMARY KEY,
+    body_sha256 text NOT NULL,
+    normalized  text NOT NULL,
+    updated_at  text NOT NULL
+);
@@ -0,0 +1,8 @@
+DELETE FROM incidents
+WHERE resolved_at IS NULL AND EXISTS (
+    SELECT 1 FROM incidents b
+    WHERE b.resolved_at IS NULL AND b.website_id = incidents.website_id
+      AND b.region = incidents.region AND b.id < incidents.id
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS incidents_active_idx ON incidents (website_id, region) WHERE resolved_at IS NULL;
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS heartbeat_monitors (
+    monitor_id    text PRIMARY KEY,
+    registered_at text NOT NULL
+);
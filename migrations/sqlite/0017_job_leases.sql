@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS job_leases (
+    name       text PRIMARY KEY,
+    holder     text NOT NULL,
+    expires_at text NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Expired idempotency keys are swept by created_at (storage.CheckRuns.Claim).
+CREATE INDEX IF NOT EXISTS check_runs_created_at_idx ON check_runs (created_at);
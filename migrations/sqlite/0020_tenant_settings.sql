@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS tenant_settings (
+    tenant_id text PRIMARY KEY,
+    timezone  text NOT NULL
+);
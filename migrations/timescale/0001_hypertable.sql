@@ -0,0 +1,10 @@
+-- Optional: convert uptime_checks into a TimescaleDB hypertable partitioned
+-- on created_at. Run after the numbered Postgres migrations on databases
+-- with the timescaledb extension available. Any primary key or unique
+-- constraint on the table must include created_at.
+CREATE EXTENSION IF NOT EXISTS timescaledb;
+
+SELECT create_hypertable('uptime_checks', 'created_at',
+    chunk_time_interval => interval '1 day',
+    migrate_data => true,
+    if_not_exists => true);
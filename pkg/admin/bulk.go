@@ -0,0 +1,142 @@
+// Package admin implements bulk operations over the websites table, so
+// groups of monitors can be changed in one call instead of one by one.
+// Like the scheduler it requires the Postgres schema.
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Selector picks websites by tag, template and/or ID. Every field that is
+// set must match; at least one must be set.
+type Selector struct {
+	Tag      string      `json:"tag,omitempty"`
+	Template string      `json:"template,omitempty"`
+	IDs      []uuid.UUID `json:"ids,omitempty"`
+}
+
+const (
+	ActionPause       = "pause"
+	ActionResume      = "resume"
+	ActionSetInterval = "setInterval"
+	ActionSetTemplate = "setTemplate"
+)
+
+type Action struct {
+	Type            string `json:"type"`
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+	Template        string `json:"template,omitempty"`
+}
+
+// Request is a bulk operation. DryRun defaults to true so a request only
+// changes anything when the caller explicitly sends "dryRun": false.
+type Request struct {
+	Selector Selector `json:"selector"`
+	Action   Action   `json:"action"`
+	DryRun   *bool    `json:"dryRun,omitempty"`
+}
+
+type Website struct {
+	ID  uuid.UUID `json:"id"`
+	URL string    `json:"url"`
+}
+
+type Response struct {
+	DryRun   bool      `json:"dryRun"`
+	Count    int       `json:"count"`
+	Websites []Website `json:"websites"`
+}
+
+// Validate reports the first problem with r.
+func (r Request) Validate() error {
+	s := r.Selector
+	if s.Tag == "" && s.Template == "" && len(s.IDs) == 0 {
+		return errors.New("selector must set at least one of tag, template or ids")
+	}
+	switch r.Action.Type {
+	case ActionPause, ActionResume:
+	case ActionSetInterval:
+		if r.Action.IntervalSeconds <= 0 {
+			return errors.New("action.intervalSeconds must be positive")
+		}
+	case ActionSetTemplate:
+		if r.Action.Template == "" {
+			return errors.New("action.template is required")
+		}
+	default:
+		return fmt.Errorf("action.type must be one of %s", strings.Join(
+			[]string{ActionPause, ActionResume, ActionSetInterval, ActionSetTemplate}, ", "))
+	}
+	return nil
+}
+
+type Bulk struct {
+	DB *sql.DB
+}
+
+// Apply runs r, or with a dry run lists the websites it would change.
+// r must already have passed Validate. Changed websites get a new version,
+// so resource API writes based on the old one are rejected.
+func (b *Bulk) Apply(ctx context.Context, r Request) (Response, error) {
+	resp := Response{DryRun: r.DryRun == nil || *r.DryRun, Websites: []Website{}}
+
+	var args []any
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	var where []string
+	if r.Selector.Tag != "" {
+		where = append(where, arg(r.Selector.Tag)+" = ANY(tags)")
+	}
+	if r.Selector.Template != "" {
+		where = append(where, "template = "+arg(r.Selector.Template))
+	}
+	if len(r.Selector.IDs) > 0 {
+		ids := make([]string, len(r.Selector.IDs))
+		for i, id := range r.Selector.IDs {
+			ids[i] = id.String()
+		}
+		where = append(where, "id = ANY("+arg(pq.Array(ids))+"::uuid[])")
+	}
+	cond := strings.Join(where, " AND ")
+
+	query := `SELECT id, url FROM websites WHERE ` + cond + ` ORDER BY id`
+	if !resp.DryRun {
+		var set string
+		switch r.Action.Type {
+		case ActionPause:
+			set = "paused = true"
+		case ActionResume:
+			set = "paused = false"
+		case ActionSetInterval:
+			set = "check_interval = " + arg(r.Action.IntervalSeconds)
+		case ActionSetTemplate:
+			set = "template = " + arg(r.Action.Template)
+		}
+		query = `UPDATE websites SET ` + set + `, version = version + 1 WHERE ` + cond + ` RETURNING id, url`
+	}
+
+	rows, err := b.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return resp, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var w Website
+		if err := rows.Scan(&w.ID, &w.URL); err != nil {
+			return resp, err
+		}
+		resp.Websites = append(resp.Websites, w)
+	}
+	resp.Count = len(resp.Websites)
+	return resp, rows.Err()
+}
@@ -0,0 +1,173 @@
+// Package auth authenticates calls to the worker.
+//
+// Callers sign each request with HMAC-SHA256 using one of the keys in
+// HMAC_KEYS ("id:secret" pairs, comma-separated, so keys can be rotated by
+// running old and new side by side) and send:
+//
+//	X-Signature-Key-Id:    id of the key used
+//	X-Signature-Timestamp: Unix time in seconds
+//	X-Signature:           hex(HMAC-SHA256(secret, timestamp + "\n" + method + "\n" + target + "\n" + body))
+//
+// where target is the path, followed by "?" and the query string with its
+// parameters sorted by name when there is one (see CanonicalTarget).
+//
+// Requests whose timestamp is more than HMAC_MAX_SKEW away from the worker's
+// clock are rejected, as are signatures already used within that window, so
+// retries must be signed again. Used signatures are remembered in process
+// memory, so each worker instance only catches replays sent to it. The
+// static X-API-Key header is still accepted while LEGACY_API_KEY_AUTH is
+// enabled, which is the default during migration.
+//
+// Key IDs not found in HMAC_KEYS are looked up in the api_keys table. Those
+// keys belong to a tenant, and their callers may only act on the tenant's
+// websites; see Caller.Foreign. Lookups are cached for AUTH_KEY_CACHE_TTL,
+// and those that miss the cache are rate limited per client address, so
+// requests with made-up key IDs cannot flood the database.
+package auth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+)
+
+const maxBodyBytes = 1 << 20
+
+var (
+	ErrMissing   = errors.New("missing credentials")
+	ErrInvalid   = errors.New("invalid credentials")
+	ErrExpired   = errors.New("signature timestamp outside allowed window")
+	ErrUnknownID = errors.New("unknown signing key")
+	ErrReplayed  = errors.New("signature already used")
+	ErrThrottled = errors.New("too many signing key lookups")
+)
+
+// Caller identifies who made an authenticated request.
+type Caller struct {
+	KeyID  string
+	Legacy bool
+
+	// TenantID is set for keys from api_keys and empty for the operator
+	// keys in HMAC_KEYS and API_KEY.
+	TenantID string
+}
+
+// Authenticate verifies r and returns the calling key. The body is read to
+// check the signature and replaced so handlers can read it again.
+func Authenticate(r *http.Request) (Caller, error) {
+	if r.Header.Get("X-Signature") != "" {
+		return verifySignature(r)
+	}
+
+	if apiKey := r.Header.Get("X-API-Key"); apiKey != "" && legacyEnabled() {
+		expected := config.String("API_KEY", "")
+		if expected == "" || subtle.ConstantTimeCompare([]byte(apiKey), []byte(expected)) != 1 {
+			return Caller{}, ErrInvalid
+		}
+		logging.From(r.Context()).Warn().Str("path", r.URL.Path).Msg("Request authenticated with deprecated X-API-Key header")
+		return Caller{KeyID: "legacy", Legacy: true}, nil
+	}
+
+	return Caller{}, ErrMissing
+}
+
+func legacyEnabled() bool {
+	return config.String("LEGACY_API_KEY_AUTH", "true") == "true"
+}
+
+func verifySignature(r *http.Request) (Caller, error) {
+	caller := Caller{KeyID: r.Header.Get("X-Signature-Key-Id")}
+	secret, ok := keys()[caller.KeyID]
+	if !ok {
+		var err error
+		if secret, caller.TenantID, err = lookupTenantKey(r, caller.KeyID); err != nil {
+			return Caller{}, err
+		}
+	}
+
+	ts, err := strconv.ParseInt(r.Header.Get("X-Signature-Timestamp"), 10, 64)
+	if err != nil {
+		return Caller{}, ErrInvalid
+	}
+	maxSkew := config.Duration("HMAC_MAX_SKEW", 5*time.Minute)
+	skew := time.Since(time.Unix(ts, 0))
+	if skew > maxSkew || skew < -maxSkew {
+		return Caller{}, ErrExpired
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodyBytes))
+	if err != nil {
+		return Caller{}, err
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature"))
+	if err != nil {
+		return Caller{}, ErrInvalid
+	}
+	if !hmac.Equal(signature, Sign(secret, ts, r.Method, CanonicalTarget(r.URL), body)) {
+		return Caller{}, ErrInvalid
+	}
+	if !replays.firstUse(caller.KeyID+":"+hex.EncodeToString(signature), time.Unix(ts, 0).Add(maxSkew), time.Now()) {
+		return Caller{}, ErrReplayed
+	}
+	return caller, nil
+}
+
+// CanonicalTarget returns the part of u covered by request signatures:
+// its path and, when it has one, its query with parameters sorted by name,
+// so proxies that reorder parameters do not break signatures.
+func CanonicalTarget(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.Path
+	}
+	return u.Path + "?" + u.Query().Encode()
+}
+
+// Sign computes the request signature for the given parts. target is the
+// request's CanonicalTarget.
+func Sign(secret string, timestamp int64, method, target string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("\n" + method + "\n" + target + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// SignRequest signs req with the key keyID from HMAC_KEYS, so workers can
+// call each other. body must be the exact request body.
+func SignRequest(req *http.Request, keyID string, body []byte) error {
+	secret, ok := keys()[keyID]
+	if !ok {
+		return ErrUnknownID
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("X-Signature-Key-Id", keyID)
+	req.Header.Set("X-Signature-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Signature", hex.EncodeToString(Sign(secret, ts, req.Method, CanonicalTarget(req.URL), body)))
+	return nil
+}
+
+func keys() map[string]string {
+	keys := map[string]string{}
+	for _, pair := range config.List("HMAC_KEYS") {
+		id, secret, found := strings.Cut(pair, ":")
+		if found && id != "" && secret != "" {
+			keys[id] = secret
+		}
+	}
+	return keys
+}
@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"encoding/hex"
+	"errors"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticateSignature(t *testing.T) {
+	t.Setenv("HMAC_KEYS", "ops:s3cret")
+	now := time.Now().Unix()
+
+	tests := []struct {
+		name    string
+		keyID   string
+		ts      int64
+		signed  string
+		sent    string
+		body    string
+		tamper  bool
+		wantErr error
+	}{
+		{name: "valid", keyID: "ops", ts: now, signed: "/v1/check", sent: "/v1/check", body: `{"a":1}`},
+		{name: "valid with query", keyID: "ops", ts: now, signed: "/v1/results?limit=5&region=eu", sent: "/v1/results?limit=5&region=eu"},
+		{name: "reordered query", keyID: "ops", ts: now, signed: "/v1/results?limit=5&region=eu", sent: "/v1/results?region=eu&limit=5"},
+		{name: "tampered query", keyID: "ops", ts: now, signed: "/v1/results?limit=5", sent: "/v1/results?limit=500", wantErr: ErrInvalid},
+		{name: "query added", keyID: "ops", ts: now, signed: "/v1/results", sent: "/v1/results?websiteId=x", wantErr: ErrInvalid},
+		{name: "tampered body", keyID: "ops", ts: now, signed: "/v1/check", sent: "/v1/check", body: `{"a":1}`, tamper: true, wantErr: ErrInvalid},
+		{name: "expired", keyID: "ops", ts: now - 600, signed: "/v1/check", sent: "/v1/check", wantErr: ErrExpired},
+		{name: "in the future", keyID: "ops", ts: now + 600, signed: "/v1/check", sent: "/v1/check", wantErr: ErrExpired},
+		{name: "unknown key", keyID: "nobody", ts: now, signed: "/v1/check", sent: "/v1/check", wantErr: ErrUnknownID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replays = &replayCache{seen: map[string]time.Time{}}
+			signedReq := httptest.NewRequest("POST", tt.signed, nil)
+			signature := hex.EncodeToString(Sign("s3cret", tt.ts, "POST", CanonicalTarget(signedReq.URL), []byte(tt.body)))
+
+			send := func() error {
+				body := tt.body
+				if tt.tamper {
+					body += " "
+				}
+				r := httptest.NewRequest("POST", tt.sent, strings.NewReader(body))
+				r.Header.Set("X-Signature-Key-Id", tt.keyID)
+				r.Header.Set("X-Signature-Timestamp", strconv.FormatInt(tt.ts, 10))
+				r.Header.Set("X-Signature", signature)
+				_, err := Authenticate(r)
+				return err
+			}
+
+			if err := send(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate() = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil {
+				if err := send(); !errors.Is(err, ErrReplayed) {
+					t.Errorf("second Authenticate() = %v, want ErrReplayed", err)
+				}
+			}
+		})
+	}
+}
+
+func TestSignRequestRoundTrip(t *testing.T) {
+	t.Setenv("HMAC_KEYS", "peer:abc,old:def")
+
+	body := `{"checks":[]}`
+	r := httptest.NewRequest("POST", "/v1/coordinate?b=2&a=1", strings.NewReader(body))
+	if err := SignRequest(r, "peer", []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	caller, err := Authenticate(r)
+	if err != nil {
+		t.Fatalf("Authenticate() = %v", err)
+	}
+	if caller.KeyID != "peer" || caller.TenantID != "" {
+		t.Errorf("Authenticate() = %+v, want operator key peer", caller)
+	}
+
+	if err := SignRequest(r, "missing", nil); !errors.Is(err, ErrUnknownID) {
+		t.Errorf("SignRequest() with unknown key = %v, want ErrUnknownID", err)
+	}
+}
+
+func TestReplayCacheExpires(t *testing.T) {
+	c := &replayCache{seen: map[string]time.Time{}}
+	start := time.Unix(1_700_000_000, 0)
+
+	if !c.firstUse("sig", start.Add(5*time.Minute), start) {
+		t.Fatal("first use rejected")
+	}
+	if c.firstUse("sig", start.Add(5*time.Minute), start.Add(time.Minute)) {
+		t.Error("replay inside the window accepted")
+	}
+	if !c.firstUse("sig", start.Add(15*time.Minute), start.Add(10*time.Minute)) {
+		t.Error("signature rejected after its entry expired")
+	}
+	if len(c.seen) != 1 {
+		t.Errorf("cache holds %d entries after sweep, want 1", len(c.seen))
+	}
+}
@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// replays remembers signatures until their timestamp leaves the allowed
+// window, after which ErrExpired rejects them anyway.
+var replays = &replayCache{seen: map[string]time.Time{}}
+
+type replayCache struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	sweptAt time.Time
+}
+
+// firstUse records signature until expires and reports whether it had not
+// been seen before. Expired entries are swept at most once a minute.
+func (c *replayCache) firstUse(signature string, expires, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if now.Sub(c.sweptAt) > time.Minute {
+		for s, exp := range c.seen {
+			if !now.Before(exp) {
+				delete(c.seen, s)
+			}
+		}
+		c.sweptAt = now
+	}
+
+	if exp, ok := c.seen[signature]; ok && now.Before(exp) {
+		return false
+	}
+	c.seen[signature] = expires
+	return true
+}
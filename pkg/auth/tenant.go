@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/cache"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/ratelimit"
+	"monitor-workder/pkg/storage"
+)
+
+var (
+	db      *sql.DB
+	dialect storage.Dialect
+
+	// tenantKeys caches api_keys lookups, including misses, so repeated
+	// requests for the same key ID reach the database once per TTL. It is
+	// built on first use, after the configuration has been loaded.
+	tenantKeys = sync.OnceValue(func() *cache.LRU[tenantKeyEntry] {
+		return cache.New[tenantKeyEntry](config.Int("AUTH_KEY_CACHE_SIZE", 10000))
+	})
+
+	// lookups throttles the database lookups left over per client address.
+	lookups = ratelimit.New()
+)
+
+type tenantKeyEntry struct {
+	secret, tenantID string
+	err              error
+}
+
+// Configure sets the database holding per-tenant keys in api_keys and
+// website ownership. Until it is called only the keys in HMAC_KEYS are
+// accepted.
+func Configure(conn *sql.DB, d storage.Dialect) {
+	db, dialect = conn, d
+}
+
+// lookupTenantKey returns the secret and tenant of keyID from the cache,
+// or from api_keys when it is not cached. Database lookups are rate
+// limited per client address and fail with ErrThrottled beyond the limit.
+func lookupTenantKey(r *http.Request, keyID string) (secret, tenantID string, err error) {
+	if db == nil || keyID == "" {
+		return "", "", ErrUnknownID
+	}
+	if e, _, ok := tenantKeys().Get(keyID, config.Duration("AUTH_KEY_CACHE_TTL", time.Minute)); ok {
+		return e.secret, e.tenantID, e.err
+	}
+
+	host, _, splitErr := net.SplitHostPort(r.RemoteAddr)
+	if splitErr != nil {
+		host = r.RemoteAddr
+	}
+	if ok, _ := lookups.Allow("auth-lookup:" + host); !ok {
+		return "", "", ErrThrottled
+	}
+
+	secret, tenantID, err = tenantKey(r.Context(), keyID)
+	if err == nil || errors.Is(err, ErrUnknownID) {
+		tenantKeys().Put(keyID, tenantKeyEntry{secret, tenantID, err})
+	}
+	return secret, tenantID, err
+}
+
+// tenantKey looks up an unrevoked key in api_keys, returning its secret
+// and tenant. A missing key is reported as ErrUnknownID.
+func tenantKey(ctx context.Context, keyID string) (secret, tenantID string, err error) {
+	if db == nil || keyID == "" {
+		return "", "", ErrUnknownID
+	}
+	err = db.QueryRowContext(ctx, dialect.Rebind(
+		`SELECT secret, tenant_id FROM api_keys WHERE id = $1 AND revoked_at IS NULL`), keyID).Scan(&secret, &tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", ErrUnknownID
+	}
+	return secret, tenantID, err
+}
+
+// IdempotencyKey scopes key to c's tenant, so one tenant reusing another's
+// Idempotency-Key or checkRunId claims its own check run instead of being
+// handed the other tenant's stored response. Operator keys share one
+// scope, which keeps queue and HTTP dispatches of the same run together.
+func (c Caller) IdempotencyKey(key string) string {
+	if c.TenantID == "" {
+		return key
+	}
+	return "tenant:" + c.TenantID + "/" + key
+}
+
+// Foreign returns the IDs in websiteIDs that do not belong to c's tenant,
+// including ones the database does not know. Callers without a tenant,
+// which authenticated with a key from HMAC_KEYS or X-API-Key, may use any
+// website.
+func (c Caller) Foreign(ctx context.Context, websiteIDs []uuid.UUID) ([]uuid.UUID, error) {
+	if c.TenantID == "" || len(websiteIDs) == 0 {
+		return nil, nil
+	}
+	if db == nil {
+		return websiteIDs, nil
+	}
+
+	args := []any{c.TenantID}
+	placeholders := make([]string, len(websiteIDs))
+	for i, id := range websiteIDs {
+		args = append(args, id.String())
+		placeholders[i] = "$" + strconv.Itoa(i+2)
+	}
+	rows, err := db.QueryContext(ctx, dialect.Rebind(
+		`SELECT id FROM websites WHERE tenant_id = $1 AND id IN (`+strings.Join(placeholders, ", ")+`)`), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	owned := map[uuid.UUID]bool{}
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		if id, err := uuid.Parse(raw); err == nil {
+			owned[id] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var foreign []uuid.UUID
+	for _, id := range websiteIDs {
+		if !owned[id] {
+			foreign = append(foreign, id)
+		}
+	}
+	return foreign, nil
+}
@@ -0,0 +1,250 @@
+// Package browser implements "browser" checks, which load the target in
+// headless Chrome so that pages whose server answers 200 but whose
+// frontend is broken are caught.
+//
+// Each check navigates to the URL, optionally waits for the waitSelector
+// in the target's options to become visible, and reports DOM-ready and
+// load timings along with console errors and uncaught exceptions. A
+// missing selector fails the check and an uncaught exception degrades it.
+//
+// Chrome is started per check from BROWSER_PATH, or from the first Chrome
+// or Chromium on PATH, at most BROWSER_MAX_CONCURRENCY at a time. Setting
+// BROWSER_WS_URL connects to a remote DevTools endpoint instead, for
+// platforms that cannot run Chrome themselves.
+package browser
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/outcome"
+)
+
+// execNames are the binaries looked up on PATH when BROWSER_PATH is unset.
+var execNames = []string{"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "headless-shell"}
+
+// navigationTimings reads DOMContentLoaded and load from the navigation
+// entry, relative to the start of navigation.
+const navigationTimings = `(() => {
+	const n = performance.getEntriesByType("navigation")[0];
+	return n ? [n.domContentLoadedEventEnd, n.loadEventEnd] : [0, 0];
+})()`
+
+type Checker struct{}
+
+// Options are the browser settings in a target's options.
+type Options struct {
+	// WaitSelector is a CSS selector to wait for after the page has
+	// loaded.
+	WaitSelector string `json:"waitSelector"`
+}
+
+func init() {
+	check.Register("browser", Checker{})
+}
+
+var (
+	slotsOnce sync.Once
+	slots     chan struct{}
+)
+
+// acquire waits for one of BROWSER_MAX_CONCURRENCY browser slots.
+func acquire(ctx context.Context) (release func(), err error) {
+	slotsOnce.Do(func() {
+		slots = make(chan struct{}, max(config.Int("BROWSER_MAX_CONCURRENCY", 2), 1))
+	})
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (Checker) Validate(target check.Target) error {
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &check.TargetError{Field: "url", Message: "must be an http or https URL"}
+	}
+	if err := target.ExpectedStatusCodes.Validate(); err != nil {
+		return &check.TargetError{Field: "expectedStatusCodes", Message: err.Error()}
+	}
+	if err := check.DecodeOptions(target, &Options{}); err != nil {
+		return err
+	}
+	if config.String("BROWSER_WS_URL", "") == "" && execPath() == "" {
+		return &check.TargetError{Field: "checkType", Message: "browser checks need Chrome or BROWSER_WS_URL on this worker"}
+	}
+	return check.ValidateProxy(target)
+}
+
+func execPath() string {
+	if path := config.String("BROWSER_PATH", ""); path != "" {
+		return path
+	}
+	for _, name := range execNames {
+		if path, err := exec.LookPath(name); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// allocate returns a context that starts a browser for target on first
+// use and stops it when cancelled.
+func allocate(ctx context.Context, target check.Target) (context.Context, context.CancelFunc) {
+	if ws := config.String("BROWSER_WS_URL", ""); ws != "" {
+		return chromedp.NewRemoteAllocator(ctx, ws)
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:], chromedp.ExecPath(execPath()))
+	proxy := target.Proxy
+	if proxy == "" {
+		proxy = config.String("CHECK_PROXY_URL", "")
+	}
+	if proxy != "" {
+		opts = append(opts, chromedp.ProxyServer(proxy))
+	}
+	return chromedp.NewExecAllocator(ctx, opts...)
+}
+
+// Check loads target in a fresh browser. The X-Uptiq-Check-Id header is
+// never sent, since the browser would also send it to every third-party
+// host the page loads from. The browser makes its own connections, so for
+// guarded checks only the page's host is checked against internal
+// addresses.
+func (Checker) Check(ctx context.Context, target check.Target) check.Result {
+	result := check.Result{WebsiteID: target.WebsiteID, URL: target.URL}
+	var opts Options
+	check.DecodeOptions(target, &opts)
+
+	if u, err := url.Parse(target.URL); err == nil {
+		if err := check.AllowedHost(ctx, u.Hostname()); err != nil {
+			result.Status = outcome.Down
+			result.FailureReason = outcome.ReasonForError(err)
+			return result
+		}
+	}
+
+	release, err := acquire(ctx)
+	if err != nil {
+		result.Status = outcome.Down
+		result.FailureReason = outcome.ReasonTimeout
+		return result
+	}
+	defer release()
+
+	ctx, cancelAlloc := allocate(ctx, target)
+	defer cancelAlloc()
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+
+	var (
+		mu   sync.Mutex
+		info check.BrowserInfo
+	)
+	chromedp.ListenTarget(ctx, func(ev any) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch ev := ev.(type) {
+		case *runtime.EventConsoleAPICalled:
+			if ev.Type == runtime.APITypeError {
+				info.ConsoleErrors = append(info.ConsoleErrors, describe(ev.Args))
+			}
+		case *runtime.EventExceptionThrown:
+			text := ev.ExceptionDetails.Text
+			if ev.ExceptionDetails.Exception != nil && ev.ExceptionDetails.Exception.Description != "" {
+				text = ev.ExceptionDetails.Exception.Description
+			}
+			info.Exceptions = append(info.Exceptions, text)
+		}
+	})
+
+	start := time.Now()
+	resp, err := chromedp.RunResponse(ctx,
+		emulation.SetUserAgentOverride(check.UserAgent(target)),
+		chromedp.Navigate(target.URL),
+	)
+	result.ResponseTime = time.Since(start).Milliseconds()
+	if err != nil {
+		logging.From(ctx).Debug().Err(err).Str("url", target.URL).Msg("Browser navigation failed")
+		result.Status = outcome.Down
+		result.FailureReason = reasonFor(ctx, err)
+		return result
+	}
+	result.StatusCode = int(resp.Status)
+	result.ContentType = resp.MimeType
+
+	status, reason := outcome.Up, ""
+	if !check.ExpectedCodes(ctx, target).Match(result.StatusCode) {
+		status, reason = outcome.Down, outcome.ReasonHTTPStatus
+	} else if opts.WaitSelector != "" {
+		if err := chromedp.Run(ctx, chromedp.WaitVisible(opts.WaitSelector, chromedp.ByQuery)); err != nil {
+			status, reason = outcome.Down, outcome.ReasonSelector
+		} else {
+			info.Selector = time.Since(start).Milliseconds()
+		}
+	}
+
+	var timings []float64
+	if err := chromedp.Run(ctx, chromedp.Evaluate(navigationTimings, &timings)); err == nil && len(timings) == 2 {
+		info.DOMContentLoaded, info.Load = int64(timings[0]), int64(timings[1])
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if status == outcome.Up && len(info.Exceptions) > 0 {
+		status, reason = outcome.Degraded, outcome.ReasonPageError
+	}
+	if status == outcome.Up && time.Duration(info.Load)*time.Millisecond > config.Duration("BROWSER_DEGRADED_AFTER", 5*time.Second) {
+		status, reason = outcome.Degraded, outcome.ReasonSlow
+	}
+	result.Status, result.FailureReason = status, reason
+	result.Browser = &info
+	return result
+}
+
+func describe(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch {
+		case arg.Description != "":
+			parts = append(parts, arg.Description)
+		case len(arg.Value) > 0:
+			parts = append(parts, strings.Trim(string(arg.Value), `"`))
+		default:
+			parts = append(parts, string(arg.Type))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// reasonFor classifies a navigation error, which Chrome reports as a
+// net::ERR_* code in the message.
+func reasonFor(ctx context.Context, err error) string {
+	msg := err.Error()
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded), strings.Contains(msg, "ERR_TIMED_OUT"):
+		return outcome.ReasonTimeout
+	case strings.Contains(msg, "ERR_NAME_NOT_RESOLVED"):
+		return outcome.ReasonDNS
+	case strings.Contains(msg, "ERR_CONNECTION_REFUSED"):
+		return outcome.ReasonConnectionRefused
+	case strings.Contains(msg, "ERR_CERT_"), strings.Contains(msg, "ERR_SSL_"):
+		return outcome.ReasonTLS
+	default:
+		return outcome.ReasonConnection
+	}
+}
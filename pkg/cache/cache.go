@@ -0,0 +1,67 @@
+// Package cache is a small in-process LRU for read endpoints whose
+// responses can be a little stale. Like the rate limiter it lives in
+// process memory, so each worker instance caches independently.
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	key      string
+	value    V
+	storedAt time.Time
+}
+
+// LRU holds up to size values, evicting the least recently used.
+type LRU[V any] struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+func New[V any](size int) *LRU[V] {
+	return &LRU[V]{size: max(size, 1), order: list.New(), items: map[string]*list.Element{}}
+}
+
+// Get returns the value stored under key if it is younger than ttl,
+// along with its age.
+func (c *LRU[V]) Get(key string, ttl time.Duration) (V, time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	el, ok := c.items[key]
+	if !ok {
+		return zero, 0, false
+	}
+	e := el.Value.(*entry[V])
+	age := time.Since(e.storedAt)
+	if age >= ttl {
+		c.order.Remove(el)
+		delete(c.items, key)
+		return zero, 0, false
+	}
+	c.order.MoveToFront(el)
+	return e.value, age, true
+}
+
+func (c *LRU[V]) Put(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		el.Value = &entry[V]{key: key, value: value, storedAt: time.Now()}
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry[V]{key: key, value: value, storedAt: time.Now()})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*entry[V]).key)
+	}
+}
@@ -0,0 +1,28 @@
+package cache
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"monitor-workder/pkg/config"
+)
+
+// SetHeaders sets Cache-Control and Age on a response that is age old and
+// fresh for ttl. Responses are private unless CACHE_PUBLIC=true, which
+// lets a CDN in front of the worker serve them too; only enable it where
+// the CDN authenticates callers or the data is not sensitive. When
+// CACHE_STALE_WHILE_REVALIDATE is set, caches may keep serving a response
+// for that long after it expires while they fetch a fresh one.
+func SetHeaders(w http.ResponseWriter, ttl, age time.Duration) {
+	value := fmt.Sprintf("private, max-age=%d", int(ttl.Seconds()))
+	if config.String("CACHE_PUBLIC", "false") == "true" {
+		value = fmt.Sprintf("public, max-age=%d, s-maxage=%d", int(ttl.Seconds()), int(ttl.Seconds()))
+	}
+	if swr := config.Duration("CACHE_STALE_WHILE_REVALIDATE", 0); swr > 0 {
+		value += fmt.Sprintf(", stale-while-revalidate=%d", int(swr.Seconds()))
+	}
+	w.Header().Set("Cache-Control", value)
+	w.Header().Set("Age", strconv.Itoa(int(age.Seconds())))
+}
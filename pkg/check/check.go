@@ -0,0 +1,294 @@
+// Package check defines check targets and results and the Checker
+// extension point that executes them.
+//
+// A new protocol lives in its own package: it implements Checker (and
+// Validator if it needs settings), registers itself under its check type
+// from init, and is linked in through the plugins package. Settings that
+// only it understands go in an options struct decoded from Target.Options
+// with DecodeOptions, rather than in new Target fields.
+//
+// The checkers built into this package and the script and heartbeat
+// checkers predate Options. Their settings (StartTLS, HoldMs, Script and
+// IntervalSeconds) stay Target fields because orchestrators already send
+// them there.
+package check
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/plugin"
+	"monitor-workder/pkg/provider"
+	"monitor-workder/pkg/traceroute"
+)
+
+type Target struct {
+	WebsiteID uuid.UUID `json:"websiteId"`
+	URL       string    `json:"url"`
+	CheckType string    `json:"checkType,omitempty"`
+	Script    string    `json:"script,omitempty"`
+	Providers []string  `json:"providers,omitempty"`
+
+	// ExpectedStatusCodes lists the status codes that count as success.
+	// When empty, DEFAULT_EXPECTED_STATUS_CODES applies, and when that is
+	// unset any status code is accepted.
+	ExpectedStatusCodes StatusCodes `json:"expectedStatusCodes,omitempty"`
+
+	// ExpectedHeaders are checked on HTTP responses that otherwise
+	// succeed. A failed assertion downgrades "up" to "degraded".
+	ExpectedHeaders HeaderAssertions `json:"expectedHeaders,omitempty"`
+
+	// HedgeDelayMs, when positive, launches a second identical request if
+	// the first has not succeeded after this many milliseconds and keeps
+	// whichever succeeds first.
+	HedgeDelayMs int `json:"hedgeDelayMs,omitempty"`
+
+	// IPVersion restricts the check to one IP family, or with "both"
+	// checks IPv4 and IPv6 separately. Empty means IPAny.
+	IPVersion string `json:"ipVersion,omitempty"`
+
+	// Proxy routes the check through this http, https or socks5 proxy
+	// instead of CHECK_PROXY_URL.
+	Proxy string `json:"proxy,omitempty"`
+
+	// IntervalSeconds is how often the monitor is checked. Heartbeat
+	// checks expect a ping at least this often.
+	IntervalSeconds int `json:"intervalSeconds,omitempty"`
+
+	// Confirmations is how many times a failed check is re-run before
+	// it is reported as "down".
+	Confirmations int `json:"confirmations,omitempty"`
+
+	// HoldMs is how long a keepalive check keeps its connection idle.
+	HoldMs int `json:"holdMs,omitempty"`
+
+	// UserAgent replaces CHECK_USER_AGENT for this target's probes.
+	UserAgent string `json:"userAgent,omitempty"`
+
+	// SendCheckID overrides CHECK_ID_HEADER, turning the X-Uptiq-Check-Id
+	// header on or off for this target.
+	SendCheckID *bool `json:"sendCheckId,omitempty"`
+
+	// StartTLS makes a mail check upgrade its plain connection with
+	// STARTTLS (STLS for POP3) after the greeting.
+	StartTLS bool `json:"startTls,omitempty"`
+
+	// Traceroute overrides TRACEROUTE_ON_FAILURE, turning path diagnostics
+	// for network-level failures on or off for this target.
+	Traceroute *bool `json:"traceroute,omitempty"`
+
+	// Content, when set, compares the normalized response body with the
+	// website's previous one and sets ContentChanged on the result when it
+	// changes by more than the tolerance.
+	Content *ContentCheck `json:"content,omitempty"`
+
+	// NoCache makes the target be checked even when a recent result for
+	// it is cached under RESULT_CACHE_TTL.
+	NoCache bool `json:"noCache,omitempty"`
+
+	// Options holds settings specific to the check type, which its
+	// checker decodes with DecodeOptions.
+	Options json.RawMessage `json:"options,omitempty"`
+}
+
+type Result struct {
+	WebsiteID    uuid.UUID `json:"websiteId"`
+	URL          string    `json:"url"`
+	Status       string    `json:"status"`
+	StatusCode   int       `json:"statusCode"`
+	ResponseTime int64     `json:"responseTime"`
+	RetryAfter   int       `json:"retryAfter,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+	CheckRunID   string    `json:"checkRunId,omitempty"`
+	RequestID    string    `json:"requestId,omitempty"`
+	CheckID      string    `json:"checkId,omitempty"`
+	Region       string    `json:"region,omitempty"`
+	Engine       string    `json:"engine,omitempty"`
+	Timings      *Timings  `json:"timings,omitempty"`
+
+	// Cached is set when the result was reused from an earlier batch
+	// instead of probing the target again. Cached results are not stored
+	// or notified a second time.
+	Cached bool `json:"cached,omitempty"`
+
+	// TLS describes the negotiated TLS session, for checks that report it.
+	TLS *TLSInfo `json:"tls,omitempty"`
+
+	// Browser holds page timings and errors, for browser checks.
+	Browser *BrowserInfo `json:"browser,omitempty"`
+
+	// FailedAssertions lists the target's header assertions that the
+	// response did not meet.
+	FailedAssertions []AssertionFailure `json:"failedAssertions,omitempty"`
+
+	// FailureReason is one of the outcome package's reasons, set when
+	// Status is not "up".
+	FailureReason string `json:"failureReason,omitempty"`
+
+	// Message is a human-readable detail from the check, such as the
+	// message a script returned or the error that stopped it.
+	Message string `json:"message,omitempty"`
+
+	ContentLength int64  `json:"contentLength,omitempty"`
+	ContentType   string `json:"contentType,omitempty"`
+	BodyHash      string `json:"bodyHash,omitempty"`
+
+	// Content describes the normalized body, for targets with a
+	// ContentCheck. ContentChanged is set when it changed by more than
+	// the target's tolerance since the previous check.
+	Content        *ContentInfo `json:"content,omitempty"`
+	ContentChanged bool         `json:"contentChanged,omitempty"`
+
+	// ProviderIncidents lists incidents declared by the target's
+	// providers at check time.
+	ProviderIncidents []provider.Incident `json:"providerIncidents,omitempty"`
+
+	// Attempts lists every request made for a hedged check. The result's
+	// own status, code and timings are those of the winning attempt.
+	Attempts []Attempt `json:"attempts,omitempty"`
+
+	// Families holds the per-family outcome when the target pins an IP
+	// version. With "both", the result itself is the worse of the two.
+	Families []FamilyResult `json:"families,omitempty"`
+
+	// Confirmation is set when the target asked for confirmations and the
+	// first attempt failed.
+	Confirmation *Confirmation `json:"confirmation,omitempty"`
+
+	// Path is the route to the target, traced after a confirmed failure
+	// to connect when path diagnostics are enabled.
+	Path *traceroute.Report `json:"path,omitempty"`
+}
+
+// Confirmation records the re-checks made after a failure. When the
+// failure is not confirmed, the result is that of the passing re-check.
+// Cancelled is set when the invocation ended before every re-check ran;
+// the failure is then reported but not confirmed.
+type Confirmation struct {
+	Rechecks  int  `json:"rechecks"`
+	Confirmed bool `json:"confirmed"`
+	Cancelled bool `json:"cancelled,omitempty"`
+}
+
+// IncidentProviders returns the distinct providers with an incident
+// annotated on r.
+func (r Result) IncidentProviders() []string {
+	var names []string
+	for _, i := range r.ProviderIncidents {
+		if !slices.Contains(names, i.Provider) {
+			names = append(names, i.Provider)
+		}
+	}
+	return names
+}
+
+// Timings breaks a request down by phase, in milliseconds.
+type Timings struct {
+	DNS       int64 `json:"dns"`
+	Connect   int64 `json:"connect"`
+	TLS       int64 `json:"tls"`
+	FirstByte int64 `json:"firstByte"`
+}
+
+// BrowserInfo describes a page load in a headless browser. Timings are in
+// milliseconds from the start of navigation.
+type BrowserInfo struct {
+	DOMContentLoaded int64    `json:"domContentLoaded"`
+	Load             int64    `json:"load"`
+	Selector         int64    `json:"selector,omitempty"`
+	ConsoleErrors    []string `json:"consoleErrors,omitempty"`
+	Exceptions       []string `json:"exceptions,omitempty"`
+}
+
+// Checker executes a single target. Check must always return a result;
+// failures are reported as a "down" status rather than an error.
+type Checker interface {
+	Check(ctx context.Context, target Target) Result
+}
+
+// Validator is implemented by checkers that need type-specific fields on
+// the target. Validate is called before any check in a batch runs.
+type Validator interface {
+	Validate(target Target) error
+}
+
+// TargetError reports a problem with a single field of a target.
+type TargetError struct {
+	Field   string
+	Message string
+}
+
+func (e *TargetError) Error() string {
+	return e.Field + " " + e.Message
+}
+
+const DefaultType = "http"
+
+var registry = plugin.NewRegistry[Checker]("checkers")
+
+// Timeout bounds how long a single check may run.
+func Timeout() time.Duration {
+	return config.Duration("CHECK_TIMEOUT", 30*time.Second)
+}
+
+// Register makes a checker available under checkType.
+func Register(checkType string, c Checker) {
+	registry.Register(checkType, c)
+}
+
+// Lookup returns the checker for target, treating an empty check type as
+// DefaultType.
+func Lookup(target Target) (Checker, error) {
+	checkType := target.CheckType
+	if checkType == "" {
+		checkType = DefaultType
+	}
+	c, ok := registry.Lookup(checkType)
+	if !ok {
+		return nil, &TargetError{
+			Field:   "checkType",
+			Message: fmt.Sprintf("must be one of %s", strings.Join(registry.Names(), ", ")),
+		}
+	}
+	return c, nil
+}
+
+// Validate reports whether target can be run by its checker.
+func Validate(target Target) error {
+	c, err := Lookup(target)
+	if err != nil {
+		return err
+	}
+	if v, ok := c.(Validator); ok {
+		return v.Validate(target)
+	}
+	return nil
+}
+
+// DecodeOptions decodes target.Options into v, a pointer to the checker's
+// options struct. Unknown fields are rejected so typos are caught when the
+// target is validated. Targets without options leave v unchanged.
+func DecodeOptions(target Target, v any) error {
+	if len(target.Options) == 0 || string(target.Options) == "null" {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(target.Options))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return &TargetError{Field: "options", Message: err.Error()}
+	}
+	return nil
+}
+
+// Types returns the registered check types.
+func Types() []string {
+	return registry.Names()
+}
@@ -0,0 +1,49 @@
+package check
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDecodeOptions(t *testing.T) {
+	type options struct {
+		Depth   int    `json:"depth"`
+		Browser string `json:"browser"`
+	}
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    options
+		wantErr bool
+	}{
+		{name: "absent", raw: "", want: options{Depth: 1}},
+		{name: "null", raw: "null", want: options{Depth: 1}},
+		{name: "partial", raw: `{"browser":"firefox"}`, want: options{Depth: 1, Browser: "firefox"}},
+		{name: "full", raw: `{"depth":3,"browser":"chromium"}`, want: options{Depth: 3, Browser: "chromium"}},
+		{name: "unknown field", raw: `{"dpth":3}`, wantErr: true},
+		{name: "wrong type", raw: `{"depth":"deep"}`, wantErr: true},
+		{name: "not an object", raw: `[1]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := options{Depth: 1}
+			err := DecodeOptions(Target{Options: json.RawMessage(tt.raw)}, &got)
+			if tt.wantErr {
+				var targetErr *TargetError
+				if !errors.As(err, &targetErr) || targetErr.Field != "options" {
+					t.Fatalf("DecodeOptions() = %v, want TargetError for options", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("DecodeOptions() = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("DecodeOptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package check
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ContentCheck asks for the normalized response body to be compared with
+// the last one seen for the website, so defacements and broken deploys
+// are caught even while the site stays up.
+type ContentCheck struct {
+	// Tolerance is the fraction of normalized lines, from 0 to 1, that may
+	// change before the result is flagged. Zero flags any change.
+	Tolerance float64 `json:"tolerance,omitempty"`
+
+	// Ignore lists regular expressions whose matches are removed before
+	// hashing, for timestamps, nonces and other text that changes on
+	// every load.
+	Ignore []string `json:"ignore,omitempty"`
+}
+
+func (c *ContentCheck) Validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Tolerance < 0 || c.Tolerance > 1 {
+		return errors.New(".tolerance must be between 0 and 1")
+	}
+	for i, pattern := range c.Ignore {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf(".ignore[%d] is not a valid regular expression: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// ContentInfo describes the normalized body of a response checked with a
+// ContentCheck. Change and Diff are filled in when the body differs from
+// the website's previous one.
+type ContentInfo struct {
+	Hash string `json:"hash"`
+
+	// Change is the fraction of normalized lines added or removed since
+	// the previous body.
+	Change float64 `json:"change,omitempty"`
+
+	// Diff lists the first removed ("- ") and added ("+ ") lines.
+	Diff string `json:"diff,omitempty"`
+
+	// Normalized is the text Hash was computed from. It is compared with
+	// the next body but never returned.
+	Normalized string `json:"-"`
+}
+
+var (
+	tagBoundary = regexp.MustCompile(`>\s*<`)
+	whitespace  = regexp.MustCompile(`[ \t\f\v]+`)
+)
+
+// NormalizeContent reduces body to the text that is compared between
+// checks: matches of the ignore patterns are removed, markup is split one
+// tag per line, runs of whitespace are collapsed and blank lines are
+// dropped. Patterns that do not compile are skipped; Validate rejects them
+// before a check runs.
+func NormalizeContent(body []byte, ignore []string) string {
+	text := string(bytes.ToValidUTF8(body, nil))
+	for _, pattern := range ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			continue
+		}
+		text = re.ReplaceAllString(text, "")
+	}
+	text = tagBoundary.ReplaceAllString(text, ">\n<")
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(whitespace.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// contentInfo normalizes body for c and hashes the result.
+func contentInfo(c *ContentCheck, body []byte) *ContentInfo {
+	normalized := NormalizeContent(body, c.Ignore)
+	sum := sha256.Sum256([]byte(normalized))
+	return &ContentInfo{Hash: hex.EncodeToString(sum[:]), Normalized: normalized}
+}
@@ -0,0 +1,124 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"syscall"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+)
+
+type guardKey struct{}
+
+// WithGuard returns a context whose checks may not connect to loopback,
+// private, link-local or other internal addresses. Checks run for tenants
+// are guarded so they cannot be pointed at the worker's own network or a
+// cloud metadata endpoint and read back what answers there.
+func WithGuard(ctx context.Context) context.Context {
+	return context.WithValue(ctx, guardKey{}, true)
+}
+
+// Guarded reports whether ctx was returned by WithGuard.
+func Guarded(ctx context.Context) bool {
+	return ctx.Value(guardKey{}) != nil
+}
+
+// Blocked reports whether guarded checks may not connect to addr:
+// loopback, private, shared (CGNAT), link-local (which includes cloud
+// metadata endpoints), unspecified and multicast addresses.
+func Blocked(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// AllowedHost checks, for guarded contexts, that host does not resolve to
+// a blocked address. Dials are checked again at connect time, which also
+// covers DNS rebinding, but connections made by a proxy or by a browser
+// can only be checked this way.
+func AllowedHost(ctx context.Context, host string) error {
+	if !Guarded(ctx) {
+		return nil
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if Blocked(addr) {
+			return fmt.Errorf("%s: %w", host, outcome.ErrBlockedAddress)
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if Blocked(addr) {
+			return fmt.Errorf("%s: %w", host, outcome.ErrBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// Dial connects like d, but for guarded contexts refuses connections to
+// blocked addresses. The operator's proxy, from CHECK_PROXY_URL or the
+// standard proxy environment variables, may be internal and is exempt;
+// a target's own proxy is not.
+func Dial(ctx context.Context, d *net.Dialer, network, address string) (net.Conn, error) {
+	if !Guarded(ctx) || operatorProxy(address) {
+		return d.DialContext(ctx, network, address)
+	}
+	guarded := *d
+	guarded.Control = refuseBlocked
+	return guarded.DialContext(ctx, network, address)
+}
+
+func refuseBlocked(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if Blocked(addrPort.Addr()) {
+		return outcome.ErrBlockedAddress
+	}
+	return nil
+}
+
+func operatorProxy(address string) bool {
+	for _, raw := range []string{
+		config.String("CHECK_PROXY_URL", ""),
+		os.Getenv("HTTP_PROXY"), os.Getenv("HTTPS_PROXY"),
+		os.Getenv("http_proxy"), os.Getenv("https_proxy"),
+	} {
+		u, err := url.Parse(raw)
+		if raw == "" || err != nil {
+			continue
+		}
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[u.Scheme]
+		}
+		if net.JoinHostPort(u.Hostname(), port) == address {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRedirect is the http.Client CheckRedirect function for check
+// clients. Like the default it stops after 10 redirects, and for guarded
+// requests it checks each new host with AllowedHost.
+func CheckRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return AllowedHost(req.Context(), req.URL.Hostname())
+}
@@ -0,0 +1,85 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+
+	"monitor-workder/pkg/outcome"
+)
+
+func TestBlocked(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"127.0.0.1", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"::1", true},
+		{"fe80::1", true},
+		{"fd00::1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		if got := Blocked(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("Blocked(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDialGuard(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name    string
+		guarded bool
+		proxy   string
+		wantErr bool
+	}{
+		{name: "unguarded", guarded: false},
+		{name: "guarded", guarded: true, wantErr: true},
+		{name: "guarded to the operator's proxy", guarded: true, proxy: "http://" + ln.Addr().String()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHECK_PROXY_URL", tt.proxy)
+			ctx := context.Background()
+			if tt.guarded {
+				ctx = WithGuard(ctx)
+			}
+
+			conn, err := Dial(ctx, &net.Dialer{}, "tcp", ln.Addr().String())
+			if conn != nil {
+				conn.Close()
+			}
+			if tt.wantErr != errors.Is(err, outcome.ErrBlockedAddress) || (!tt.wantErr && err != nil) {
+				t.Errorf("Dial() = %v, want blocked %v", err, tt.wantErr)
+			}
+			if err := AllowedHost(ctx, "127.0.0.1"); tt.guarded != errors.Is(err, outcome.ErrBlockedAddress) {
+				t.Errorf("AllowedHost() = %v, want blocked %v", err, tt.guarded)
+			}
+		})
+	}
+}
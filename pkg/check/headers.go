@@ -0,0 +1,75 @@
+package check
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+)
+
+// HeaderAssertion is an expectation about one response header. By default
+// the header must be present; Matches additionally requires its value to
+// match a regular expression, and Absent requires it to be missing.
+type HeaderAssertion struct {
+	Name    string `json:"name"`
+	Matches string `json:"matches,omitempty"`
+	Absent  bool   `json:"absent,omitempty"`
+}
+
+// AssertionFailure reports a header assertion that did not hold.
+type AssertionFailure struct {
+	Header  string `json:"header"`
+	Message string `json:"message"`
+	Actual  string `json:"actual,omitempty"`
+}
+
+// HeaderAssertions is a target's list of expected response headers.
+type HeaderAssertions []HeaderAssertion
+
+func (a HeaderAssertions) Validate() error {
+	for i, h := range a {
+		if h.Name == "" {
+			return fmt.Errorf("[%d].name is required", i)
+		}
+		if h.Absent && h.Matches != "" {
+			return fmt.Errorf("[%d] cannot both be absent and match a pattern", i)
+		}
+		if _, err := regexp.Compile(h.Matches); err != nil {
+			return fmt.Errorf("[%d].matches is not a valid regular expression: %s", i, err)
+		}
+	}
+	return nil
+}
+
+// Check returns the assertions header fails. Patterns are matched against
+// each value of a repeated header and pass if any value matches.
+func (a HeaderAssertions) Check(header http.Header) []AssertionFailure {
+	var failures []AssertionFailure
+	for _, h := range a {
+		values := header.Values(h.Name)
+		switch {
+		case h.Absent:
+			if len(values) > 0 {
+				failures = append(failures, AssertionFailure{Header: h.Name, Message: "must be absent", Actual: values[0]})
+			}
+		case len(values) == 0:
+			failures = append(failures, AssertionFailure{Header: h.Name, Message: "is missing"})
+		case h.Matches != "":
+			re := regexp.MustCompile(h.Matches)
+			matched := false
+			for _, v := range values {
+				if re.MatchString(v) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				failures = append(failures, AssertionFailure{
+					Header:  h.Name,
+					Message: "does not match " + h.Matches,
+					Actual:  values[0],
+				})
+			}
+		}
+	}
+	return failures
+}
@@ -0,0 +1,59 @@
+package check
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHeaderAssertionsValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		assertions HeaderAssertions
+		wantErr    bool
+	}{
+		{"presence", HeaderAssertions{{Name: "ETag"}}, false},
+		{"pattern", HeaderAssertions{{Name: "Content-Type", Matches: "^text/"}}, false},
+		{"absent", HeaderAssertions{{Name: "Server", Absent: true}}, false},
+		{"missing name", HeaderAssertions{{Matches: "x"}}, true},
+		{"absent with pattern", HeaderAssertions{{Name: "Server", Absent: true, Matches: "nginx"}}, true},
+		{"bad pattern", HeaderAssertions{{Name: "Server", Matches: "("}}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.assertions.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHeaderAssertionsCheck(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Add("Cache-Control", "no-store")
+	header.Add("Cache-Control", "max-age=0")
+	header.Set("Server", "nginx")
+
+	tests := []struct {
+		name      string
+		assertion HeaderAssertion
+		want      string
+	}{
+		{"present", HeaderAssertion{Name: "content-type"}, ""},
+		{"missing", HeaderAssertion{Name: "ETag"}, "is missing"},
+		{"matches", HeaderAssertion{Name: "Content-Type", Matches: "^text/html"}, ""},
+		{"does not match", HeaderAssertion{Name: "Content-Type", Matches: "json"}, "does not match json"},
+		{"any repeated value matches", HeaderAssertion{Name: "Cache-Control", Matches: "^max-age"}, ""},
+		{"absent but present", HeaderAssertion{Name: "Server", Absent: true}, "must be absent"},
+		{"absent", HeaderAssertion{Name: "X-Powered-By", Absent: true}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures := HeaderAssertions{tt.assertion}.Check(header)
+			switch {
+			case tt.want == "" && len(failures) > 0:
+				t.Errorf("Check() = %+v, want no failures", failures)
+			case tt.want != "" && (len(failures) != 1 || failures[0].Message != tt.want):
+				t.Errorf("Check() = %+v, want %q", failures, tt.want)
+			}
+		})
+	}
+}
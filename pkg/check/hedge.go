@@ -0,0 +1,106 @@
+package check
+
+import (
+	"context"
+	"time"
+)
+
+// Attempt records one of the requests made for a hedged check.
+type Attempt struct {
+	// StartedAfterMs is how long after the check began the attempt was
+	// launched: zero for the first, and for the second the hedge delay or
+	// however long the first took to fail if that was sooner.
+	StartedAfterMs int64  `json:"startedAfterMs"`
+	Status         string `json:"status"`
+	StatusCode     int    `json:"statusCode"`
+	ResponseTime   int64  `json:"responseTime"`
+	Winner         bool   `json:"winner,omitempty"`
+}
+
+// hedge runs attempt and, if it has not succeeded within delay or fails
+// before then, launches a second identical one. The first attempt to finish with anything other
+// than "down" wins and the other is cancelled and recorded as
+// "cancelled". If both fail, the first attempt's result is returned.
+func hedge(ctx context.Context, delay time.Duration, attempt func(context.Context) Result) Result {
+	type outcome struct {
+		index  int
+		result Result
+	}
+
+	var (
+		start    = time.Now()
+		done     = make(chan outcome, 2)
+		cancels  []context.CancelFunc
+		attempts []Attempt
+		results  []*Result
+	)
+	launch := func() {
+		actx, cancel := context.WithCancel(ctx)
+		index := len(cancels)
+		cancels = append(cancels, cancel)
+		attempts = append(attempts, Attempt{StartedAfterMs: time.Since(start).Milliseconds()})
+		results = append(results, nil)
+		go func() { done <- outcome{index, attempt(actx)} }()
+	}
+	defer func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+	}()
+
+	launch()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	winner := -1
+	for pending := 1; pending > 0 && winner < 0; {
+		select {
+		case <-timer.C:
+			launch()
+			pending++
+		case o := <-done:
+			pending--
+			results[o.index] = &o.result
+			if o.result.Status != "down" {
+				winner = o.index
+			} else if len(cancels) == 1 && ctx.Err() == nil {
+				timer.Stop()
+				launch()
+				pending++
+			}
+		}
+	}
+
+	if winner < 0 {
+		winner = 0
+	}
+	cancelled := make([]bool, len(cancels))
+	for i, cancel := range cancels {
+		if results[i] == nil {
+			cancel()
+			cancelled[i] = true
+		}
+	}
+	for _, c := range cancelled {
+		if c {
+			o := <-done
+			results[o.index] = &o.result
+		}
+	}
+
+	for i, r := range results {
+		attempts[i].Status = r.Status
+		attempts[i].StatusCode = r.StatusCode
+		attempts[i].ResponseTime = r.ResponseTime
+		if cancelled[i] {
+			attempts[i].Status = "cancelled"
+		}
+	}
+	attempts[winner].Winner = true
+
+	result := *results[winner]
+	if len(attempts) > 1 {
+		result.Attempts = attempts
+	}
+	return result
+}
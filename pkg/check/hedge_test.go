@@ -0,0 +1,47 @@
+package check
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHedgeLaunchesOnEarlyFailure(t *testing.T) {
+	var calls atomic.Int32
+	attempt := func(ctx context.Context) Result {
+		if calls.Add(1) == 1 {
+			return Result{Status: "down", StatusCode: 502}
+		}
+		return Result{Status: "up", StatusCode: 200}
+	}
+
+	start := time.Now()
+	result := hedge(context.Background(), time.Hour, attempt)
+	if elapsed := time.Since(start); elapsed > time.Minute {
+		t.Fatalf("hedge() waited %v for the hedge delay", elapsed)
+	}
+	if result.Status != "up" {
+		t.Errorf("hedge() status = %q, want up", result.Status)
+	}
+	if len(result.Attempts) != 2 {
+		t.Fatalf("hedge() made %d attempts, want 2", len(result.Attempts))
+	}
+	if first := result.Attempts[0]; first.Status != "down" || first.Winner {
+		t.Errorf("first attempt = %+v, want a losing down", first)
+	}
+	if second := result.Attempts[1]; second.Status != "up" || !second.Winner {
+		t.Errorf("second attempt = %+v, want the winning up", second)
+	}
+}
+
+func TestHedgeBothFail(t *testing.T) {
+	attempt := func(ctx context.Context) Result {
+		return Result{Status: "down"}
+	}
+
+	result := hedge(context.Background(), time.Hour, attempt)
+	if result.Status != "down" || len(result.Attempts) != 2 || !result.Attempts[0].Winner {
+		t.Errorf("hedge() = %s %+v, want the first of two failures", result.Status, result.Attempts)
+	}
+}
@@ -0,0 +1,316 @@
+package check
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/flags"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/outcome"
+)
+
+type httpChecker struct{}
+
+func init() {
+	Register("http", httpChecker{})
+}
+
+func (httpChecker) Validate(target Target) error {
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return &TargetError{Field: "url", Message: "must be an http or https URL"}
+	}
+	if u.Host == "" {
+		return &TargetError{Field: "url", Message: "must include a host"}
+	}
+	if err := target.ExpectedStatusCodes.Validate(); err != nil {
+		return &TargetError{Field: "expectedStatusCodes", Message: err.Error()}
+	}
+	if err := target.ExpectedHeaders.Validate(); err != nil {
+		return &TargetError{Field: "expectedHeaders", Message: err.Error()}
+	}
+	if target.HedgeDelayMs < 0 {
+		return &TargetError{Field: "hedgeDelayMs", Message: "must not be negative"}
+	}
+	if !validIPVersion(target.IPVersion) {
+		return &TargetError{Field: "ipVersion", Message: "must be one of any, ipv4, ipv6, both"}
+	}
+	if err := target.Content.Validate(); err != nil {
+		return &TargetError{Field: "content", Message: err.Error()}
+	}
+	if strings.ContainsAny(target.UserAgent, "\r\n\x00") {
+		return &TargetError{Field: "userAgent", Message: "must not contain control characters"}
+	}
+	return ValidateProxy(target)
+}
+
+var v1Clients = familyClients(true)
+
+func (c httpChecker) Check(ctx context.Context, target Target) Result {
+	ctx = WithIdentity(WithProxy(ctx, target.Proxy), target)
+	if u, err := url.Parse(target.URL); err == nil {
+		if err := AllowedHost(ctx, u.Hostname()); err != nil {
+			return Result{WebsiteID: target.WebsiteID, URL: target.URL, Status: "down", FailureReason: outcome.ReasonForError(err)}
+		}
+	}
+	switch target.IPVersion {
+	case IPBoth:
+		return checkFamilies(ctx, target, c.hedged)
+	case IPv4, IPv6:
+		result := c.hedged(ctx, target)
+		result.Families = []FamilyResult{familyResult(target.IPVersion, result)}
+		return result
+	}
+	return c.hedged(ctx, target)
+}
+
+func (c httpChecker) hedged(ctx context.Context, target Target) Result {
+	if target.HedgeDelayMs > 0 {
+		delay := time.Duration(target.HedgeDelayMs) * time.Millisecond
+		return hedge(ctx, delay, func(ctx context.Context) Result {
+			return c.attempt(ctx, target)
+		})
+	}
+	return c.attempt(ctx, target)
+}
+
+func (httpChecker) attempt(ctx context.Context, target Target) Result {
+	if flags.Enabled(flags.HTTPEngineV2, target.WebsiteID.String()) {
+		return HTTPv2{}.Check(ctx, target)
+	}
+
+	result := Result{
+		WebsiteID: target.WebsiteID,
+		URL:       target.URL,
+		Engine:    "v1",
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		result.Status = "down"
+		return result
+	}
+	Identify(req)
+
+	start := time.Now()
+	resp, err := clientFor(v1Clients, target).Do(req)
+	result.ResponseTime = time.Since(start).Milliseconds()
+
+	if err != nil {
+		result.Status = "down"
+		result.StatusCode = 0
+		result.FailureReason = outcome.ReasonForError(err)
+	} else {
+		defer resp.Body.Close()
+		classify(ctx, &result, resp, target)
+		if err := readBody(&result, resp, target); err != nil {
+			logging.From(ctx).Debug().Err(err).Str("url", target.URL).Msg("Error reading response body")
+		}
+	}
+
+	return result
+}
+
+// readBody consumes up to MAX_BODY_BYTES of the response, recording how
+// many bytes were read, the content type and a SHA-256 of the body. For
+// targets with a ContentCheck the first CONTENT_MAX_BYTES are also kept
+// and normalized.
+func readBody(result *Result, resp *http.Response, target Target) error {
+	result.ContentType = resp.Header.Get("Content-Type")
+
+	h := sha256.New()
+	var w io.Writer = h
+	var body *bytes.Buffer
+	if target.Content != nil {
+		body = &bytes.Buffer{}
+		w = io.MultiWriter(h, &limitedWriter{w: body, n: config.Int("CONTENT_MAX_BYTES", 256<<10)})
+	}
+
+	limit := int64(config.Int("MAX_BODY_BYTES", 10<<20))
+	n, err := io.Copy(w, io.LimitReader(resp.Body, limit))
+	result.ContentLength = n
+	result.BodyHash = hex.EncodeToString(h.Sum(nil))
+	if body != nil && err == nil {
+		result.Content = contentInfo(target.Content, body.Bytes())
+	}
+	return err
+}
+
+// limitedWriter keeps the first n bytes written to it and discards the
+// rest without failing the copy.
+type limitedWriter struct {
+	w io.Writer
+	n int
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.n > 0 {
+		keep := p[:min(len(p), l.n)]
+		l.n -= len(keep)
+		if _, err := l.w.Write(keep); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// classify sets the status of a check that got a response.
+func classify(ctx context.Context, result *Result, resp *http.Response, target Target) {
+	result.StatusCode = resp.StatusCode
+
+	if retryAfter, ok := throttled(resp); ok {
+		result.Status = "throttled"
+		result.FailureReason = outcome.ReasonThrottled
+		result.RetryAfter = retryAfter
+		return
+	}
+
+	if !ExpectedCodes(ctx, target).Match(resp.StatusCode) {
+		result.Status = "down"
+		result.FailureReason = outcome.ReasonHTTPStatus
+		return
+	}
+
+	if result.ResponseTime > 1000 {
+		result.Status = "degraded"
+		result.FailureReason = outcome.ReasonSlow
+	} else {
+		result.Status = "up"
+	}
+
+	if failures := target.ExpectedHeaders.Check(resp.Header); len(failures) > 0 {
+		result.FailedAssertions = failures
+		result.Status = "degraded"
+		result.FailureReason = outcome.ReasonHeaderAssertion
+	}
+}
+
+// ExpectedCodes returns the status codes that count as success for
+// target: its ExpectedStatusCodes or DEFAULT_EXPECTED_STATUS_CODES.
+func ExpectedCodes(ctx context.Context, target Target) StatusCodes {
+	if len(target.ExpectedStatusCodes) > 0 {
+		return target.ExpectedStatusCodes
+	}
+	return defaultStatusCodes(ctx)
+}
+
+func defaultStatusCodes(ctx context.Context) StatusCodes {
+	codes := ParseStatusCodes(config.String("DEFAULT_EXPECTED_STATUS_CODES", ""))
+	if err := codes.Validate(); err != nil {
+		logging.From(ctx).Warn().Err(err).Msg("Ignoring invalid DEFAULT_EXPECTED_STATUS_CODES")
+		return nil
+	}
+	return codes
+}
+
+// throttled reports whether resp asks the client to back off: any 429, or a
+// 503 carrying Retry-After. The returned delay is in seconds and is zero
+// when the header is absent or unparseable. Detection can be turned off
+// with THROTTLE_DETECTION=false, in which case such responses are
+// classified like any other.
+func throttled(resp *http.Response) (int, bool) {
+	if config.String("THROTTLE_DETECTION", "true") != "true" {
+		return 0, false
+	}
+
+	header := resp.Header.Get("Retry-After")
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusServiceUnavailable && header != "":
+	default:
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(header); err == nil && secs > 0 {
+		return secs, true
+	}
+	if at, err := http.ParseTime(header); err == nil {
+		if d := time.Until(at); d > 0 {
+			return int(d.Seconds() + 0.5), true
+		}
+	}
+	return 0, true
+}
+
+// v2Clients never reuse connections, so every check pays for its own DNS
+// lookup, connect and TLS handshake and the timings reflect a cold client.
+var v2Clients = familyClients(false)
+
+// HTTPv2 is the candidate replacement HTTP engine, used for sites selected
+// by the http_engine_v2 flag. Unlike v1 it measures the full body transfer
+// and records a per-phase timing breakdown.
+type HTTPv2 struct{}
+
+func (HTTPv2) Check(ctx context.Context, target Target) Result {
+	ctx = WithIdentity(WithProxy(ctx, target.Proxy), target)
+	result := Result{
+		WebsiteID: target.WebsiteID,
+		URL:       target.URL,
+		Engine:    "v2",
+	}
+
+	var (
+		timings                                      Timings
+		dnsStart, connectStart, tlsStart, reqWritten time.Time
+	)
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timings.DNS = time.Since(dnsStart).Milliseconds()
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			timings.Connect = time.Since(connectStart).Milliseconds()
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timings.TLS = time.Since(tlsStart).Milliseconds()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { reqWritten = time.Now() },
+		GotFirstResponseByte: func() {
+			timings.FirstByte = time.Since(reqWritten).Milliseconds()
+		},
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target.URL, nil)
+	if err != nil {
+		result.Status = "down"
+		return result
+	}
+	Identify(req)
+
+	start := time.Now()
+	resp, err := clientFor(v2Clients, target).Do(req)
+	if err == nil {
+		err = readBody(&result, resp, target)
+		resp.Body.Close()
+	}
+	result.ResponseTime = time.Since(start).Milliseconds()
+	result.Timings = &timings
+
+	if resp == nil {
+		result.Status = "down"
+		result.FailureReason = outcome.ReasonForError(err)
+		return result
+	}
+
+	if err != nil {
+		result.StatusCode = resp.StatusCode
+		result.Status = "down"
+		result.FailureReason = outcome.ReasonBody
+		return result
+	}
+	classify(ctx, &result, resp, target)
+	return result
+}
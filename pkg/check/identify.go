@@ -0,0 +1,62 @@
+package check
+
+import (
+	"context"
+	"net/http"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/version"
+)
+
+// CheckIDHeader carries the website ID on outgoing probes so target sites
+// can tell which monitor is calling.
+const CheckIDHeader = "X-Uptiq-Check-Id"
+
+type identityKey struct{}
+
+type identity struct {
+	userAgent string
+	checkID   string
+}
+
+// WithIdentity returns a context whose outbound check requests identify
+// themselves as configured for target: its UserAgent or CHECK_USER_AGENT,
+// and the X-Uptiq-Check-Id header when target.SendCheckID or
+// CHECK_ID_HEADER asks for it.
+func WithIdentity(ctx context.Context, target Target) context.Context {
+	id := identity{userAgent: UserAgent(target)}
+
+	send := config.String("CHECK_ID_HEADER", "false") == "true"
+	if target.SendCheckID != nil {
+		send = *target.SendCheckID
+	}
+	if send {
+		id.checkID = target.WebsiteID.String()
+	}
+	return context.WithValue(ctx, identityKey{}, id)
+}
+
+// UserAgent returns the User-Agent target's probes send: its UserAgent or
+// CHECK_USER_AGENT.
+func UserAgent(target Target) string {
+	if target.UserAgent != "" {
+		return target.UserAgent
+	}
+	return config.String("CHECK_USER_AGENT", "Uptiq-Monitor/"+version.Get().Version)
+}
+
+// Identify sets the identification headers from req's context, unless the
+// request already carries its own User-Agent. Requests whose context did
+// not go through WithIdentity are left as they are.
+func Identify(req *http.Request) {
+	id, ok := req.Context().Value(identityKey{}).(identity)
+	if !ok {
+		return
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", id.userAgent)
+	}
+	if id.checkID != "" {
+		req.Header.Set(CheckIDHeader, id.checkID)
+	}
+}
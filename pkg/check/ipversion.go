@@ -0,0 +1,105 @@
+package check
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/outcome"
+)
+
+// IP versions accepted in Target.IPVersion. IPAny lets the resolver and
+// dialer pick, as a browser would; IPBoth checks each family separately.
+const (
+	IPAny  = "any"
+	IPv4   = "ipv4"
+	IPv6   = "ipv6"
+	IPBoth = "both"
+)
+
+// FamilyResult is the outcome of a check over one IP family.
+type FamilyResult struct {
+	Family       string `json:"family"`
+	Status       string `json:"status"`
+	StatusCode   int    `json:"statusCode"`
+	ResponseTime int64  `json:"responseTime"`
+}
+
+func validIPVersion(v string) bool {
+	switch v {
+	case "", IPAny, IPv4, IPv6, IPBoth:
+		return true
+	}
+	return false
+}
+
+// familyClients returns one client per IP version, each dialing only over
+// its own family so pooled connections are never shared between them.
+func familyClients(keepAlives bool) map[string]*http.Client {
+	clients := make(map[string]*http.Client)
+	for family, network := range map[string]string{IPAny: "", IPv4: "tcp4", IPv6: "tcp6"} {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.DisableKeepAlives = !keepAlives
+		transport.Proxy = Proxy
+		transport.DialContext = func(ctx context.Context, n, addr string) (net.Conn, error) {
+			if network != "" {
+				n = network
+			}
+			return Dial(ctx, dialer, n, addr)
+		}
+		clients[family] = &http.Client{Transport: transport, CheckRedirect: CheckRedirect}
+	}
+	return clients
+}
+
+// clientFor picks the client for target's IP version from clients.
+func clientFor(clients map[string]*http.Client, target Target) *http.Client {
+	if c, ok := clients[target.IPVersion]; ok {
+		return c
+	}
+	return clients[IPAny]
+}
+
+// checkFamilies runs check once per IP family concurrently and returns the
+// worst of the results, annotated with every family's outcome.
+func checkFamilies(ctx context.Context, target Target, check func(context.Context, Target) Result) Result {
+	families := []string{IPv4, IPv6}
+	results := make([]Result, len(families))
+
+	var wg sync.WaitGroup
+	for i, family := range families {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t := target
+			t.IPVersion = family
+			results[i] = check(ctx, t)
+		}()
+	}
+	wg.Wait()
+
+	worst := 0
+	var perFamily []FamilyResult
+	for i, r := range results {
+		perFamily = append(perFamily, familyResult(families[i], r))
+		if outcome.Rank(r.Status) > outcome.Rank(results[worst].Status) {
+			worst = i
+		}
+	}
+
+	result := results[worst]
+	result.Families = perFamily
+	return result
+}
+
+func familyResult(family string, r Result) FamilyResult {
+	return FamilyResult{
+		Family:       family,
+		Status:       r.Status,
+		StatusCode:   r.StatusCode,
+		ResponseTime: r.ResponseTime,
+	}
+}
@@ -0,0 +1,175 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+)
+
+// keepaliveChecker holds a connection idle for the target's HoldMs and
+// reports "down" if the server drops it early, or if the check times out
+// before the hold is over. With a tcp:// URL it keeps
+// a raw TCP connection open; with http(s):// it makes a request, waits,
+// then makes a second one and requires it to reuse the first connection.
+// Only HTTP probes go through the check proxy; raw TCP probes connect
+// directly.
+type keepaliveChecker struct{}
+
+func init() {
+	Register("keepalive", keepaliveChecker{})
+}
+
+func maxHold() time.Duration {
+	return config.Duration("KEEPALIVE_MAX_HOLD", 25*time.Second)
+}
+
+func (keepaliveChecker) Validate(target Target) error {
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != "tcp" && u.Scheme != "http" && u.Scheme != "https") {
+		return &TargetError{Field: "url", Message: "must be a tcp, http or https URL"}
+	}
+	if u.Host == "" || (u.Scheme == "tcp" && u.Port() == "") {
+		return &TargetError{Field: "url", Message: "must include a host and, for tcp, a port"}
+	}
+	if u.Scheme == "tcp" && target.Proxy != "" {
+		return &TargetError{Field: "proxy", Message: "is not supported for tcp URLs"}
+	}
+	if target.HoldMs <= 0 {
+		return &TargetError{Field: "holdMs", Message: "is required for keepalive checks"}
+	}
+	hold := time.Duration(target.HoldMs) * time.Millisecond
+	if hold > maxHold() {
+		return &TargetError{Field: "holdMs", Message: "must not exceed " + maxHold().String()}
+	}
+	if hold >= Timeout() {
+		return &TargetError{Field: "holdMs", Message: "must be shorter than CHECK_TIMEOUT (" + Timeout().String() + ")"}
+	}
+	return ValidateProxy(target)
+}
+
+func (keepaliveChecker) Check(ctx context.Context, target Target) Result {
+	result := Result{
+		WebsiteID: target.WebsiteID,
+		URL:       target.URL,
+	}
+	hold := time.Duration(target.HoldMs) * time.Millisecond
+
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		result.Status = "down"
+		return result
+	}
+	if err := AllowedHost(ctx, u.Hostname()); err != nil {
+		result.Status = "down"
+		result.FailureReason = outcome.ReasonForError(err)
+		return result
+	}
+	if u.Scheme == "tcp" {
+		result.Status = holdTCP(ctx, u.Host, hold, &result)
+	} else {
+		result.Status = holdHTTP(WithIdentity(WithProxy(ctx, target.Proxy), target), target, hold, &result)
+	}
+	return result
+}
+
+// holdTCP connects to addr and waits out hold, discarding anything the
+// server sends. The connection must still be open at the end, and the
+// check fails with a timeout if ctx ends before hold does.
+func holdTCP(ctx context.Context, addr string, hold time.Duration, result *Result) string {
+	var d net.Dialer
+	start := time.Now()
+	conn, err := Dial(ctx, &d, "tcp", addr)
+	result.ResponseTime = time.Since(start).Milliseconds()
+	if err != nil {
+		result.FailureReason = outcome.ReasonForError(err)
+		return "down"
+	}
+	defer conn.Close()
+
+	end := time.Now().Add(hold)
+	deadline := end
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	_, err = io.Copy(io.Discard, conn)
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		result.FailureReason = outcome.ReasonConnectionReuse
+		return "down"
+	}
+	if ctx.Err() != nil || time.Now().Before(end) {
+		result.FailureReason = outcome.ReasonTimeout
+		return "down"
+	}
+	return "up"
+}
+
+// holdHTTP makes two requests hold apart on a dedicated client and
+// requires the second to reuse the first's connection.
+func holdHTTP(ctx context.Context, target Target, hold time.Duration, result *Result) string {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.IdleConnTimeout = 0
+	transport.Proxy = Proxy
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return Dial(ctx, dialer, network, addr)
+	}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport, CheckRedirect: CheckRedirect}
+
+	do := func() (*http.Response, bool, error) {
+		var reused bool
+		trace := &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) { reused = info.Reused },
+		}
+		req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target.URL, nil)
+		if err != nil {
+			return nil, false, err
+		}
+		Identify(req)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, false, err
+		}
+		// The body must be drained for the connection to return to the pool.
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return resp, reused, nil
+	}
+
+	if _, _, err := do(); err != nil {
+		result.FailureReason = outcome.ReasonForError(err)
+		return "down"
+	}
+
+	select {
+	case <-time.After(hold):
+	case <-ctx.Done():
+		result.FailureReason = outcome.ReasonTimeout
+		return "down"
+	}
+
+	start := time.Now()
+	resp, reused, err := do()
+	result.ResponseTime = time.Since(start).Milliseconds()
+	if err != nil {
+		result.FailureReason = outcome.ReasonForError(err)
+		return "down"
+	}
+	result.StatusCode = resp.StatusCode
+	if !reused {
+		result.FailureReason = outcome.ReasonConnectionReuse
+		return "down"
+	}
+	return "up"
+}
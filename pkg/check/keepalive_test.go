@@ -0,0 +1,80 @@
+package check
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"monitor-workder/pkg/outcome"
+)
+
+func TestKeepaliveValidateHold(t *testing.T) {
+	t.Setenv("CHECK_TIMEOUT", "2s")
+
+	tests := []struct {
+		holdMs  int
+		wantErr bool
+	}{
+		{holdMs: 0, wantErr: true},
+		{holdMs: 500, wantErr: false},
+		{holdMs: 1999, wantErr: false},
+		{holdMs: 2000, wantErr: true},
+		{holdMs: 5000, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := keepaliveChecker{}.Validate(Target{URL: "tcp://example.com:25", HoldMs: tt.holdMs})
+		var targetErr *TargetError
+		if tt.wantErr != (errors.As(err, &targetErr) && targetErr.Field == "holdMs") {
+			t.Errorf("Validate(holdMs %d) = %v, want error %v", tt.holdMs, err, tt.wantErr)
+		}
+	}
+}
+
+func TestKeepaliveHoldTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closeEarly := make(chan bool, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if <-closeEarly {
+				conn.Close()
+				continue
+			}
+			defer conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name       string
+		hold       time.Duration
+		timeout    time.Duration
+		closeEarly bool
+		want       string
+		reason     string
+	}{
+		{name: "held", hold: 50 * time.Millisecond, timeout: time.Second, want: "up"},
+		{name: "dropped by server", hold: time.Second, timeout: 2 * time.Second, closeEarly: true, want: "down", reason: outcome.ReasonConnectionReuse},
+		{name: "cut short by timeout", hold: time.Second, timeout: 50 * time.Millisecond, want: "down", reason: outcome.ReasonTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			closeEarly <- tt.closeEarly
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			var result Result
+			if got := holdTCP(ctx, ln.Addr().String(), tt.hold, &result); got != tt.want || result.FailureReason != tt.reason {
+				t.Errorf("holdTCP() = %s (%q), want %s (%q)", got, result.FailureReason, tt.want, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package check
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+)
+
+// TLSInfo describes the TLS session a check negotiated.
+type TLSInfo struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipherSuite"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"notAfter,omitempty"`
+}
+
+func tlsInfo(state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Issuer = cert.Issuer.String()
+		info.NotAfter = cert.NotAfter
+	}
+	return info
+}
+
+// mailChecker connects to a mail server, reads its greeting, optionally
+// upgrades the connection with STARTTLS, and logs out. The URL scheme
+// picks plain (smtp, imap, pop3) or implicit TLS (smtps, imaps, pop3s);
+// StartTLS is only allowed on plain schemes. Mail checks connect directly,
+// never through the check proxy.
+type mailChecker struct {
+	protocol mailProtocol
+}
+
+// mailProtocol is one protocol's side of the conversation. Each function
+// must leave the connection ready for the next step.
+type mailProtocol struct {
+	name      string
+	plainPort string
+	tlsPort   string
+	greet     func(c *textproto.Conn) error
+	startTLS  func(c *textproto.Conn) error
+	afterTLS  func(c *textproto.Conn) error
+	quit      func(c *textproto.Conn) error
+}
+
+func init() {
+	Register("smtp", mailChecker{smtpProtocol})
+	Register("imap", mailChecker{imapProtocol})
+	Register("pop3", mailChecker{pop3Protocol})
+}
+
+func (m mailChecker) Validate(target Target) error {
+	p := m.protocol
+	u, err := url.Parse(target.URL)
+	if err != nil || (u.Scheme != p.name && u.Scheme != p.name+"s") {
+		return &TargetError{Field: "url", Message: fmt.Sprintf("must be a %s or %ss URL", p.name, p.name)}
+	}
+	if u.Hostname() == "" {
+		return &TargetError{Field: "url", Message: "must include a host"}
+	}
+	if target.StartTLS && u.Scheme == p.name+"s" {
+		return &TargetError{Field: "startTls", Message: "cannot be combined with implicit TLS"}
+	}
+	if target.Proxy != "" {
+		return &TargetError{Field: "proxy", Message: "is not supported for mail checks"}
+	}
+	return nil
+}
+
+func (m mailChecker) Check(ctx context.Context, target Target) Result {
+	result := Result{
+		WebsiteID: target.WebsiteID,
+		URL:       target.URL,
+		Status:    "down",
+	}
+	start := time.Now()
+	err := m.converse(ctx, target, &result)
+	result.ResponseTime = time.Since(start).Milliseconds()
+
+	var protoErr *textproto.Error
+	switch {
+	case err == nil:
+		result.Status = "up"
+	case errors.As(err, &protoErr), errors.Is(err, errProtocol):
+		result.FailureReason = outcome.ReasonProtocol
+	default:
+		result.FailureReason = outcome.ReasonForError(err)
+	}
+	return result
+}
+
+var errProtocol = errors.New("unexpected server response")
+
+func (m mailChecker) converse(ctx context.Context, target Target, result *Result) error {
+	p := m.protocol
+	u, _ := url.Parse(target.URL)
+	implicitTLS := u.Scheme == p.name+"s"
+	port := u.Port()
+	if port == "" {
+		port = p.plainPort
+		if implicitTLS {
+			port = p.tlsPort
+		}
+	}
+	addr := net.JoinHostPort(u.Hostname(), port)
+	tlsConfig := &tls.Config{ServerName: u.Hostname()}
+	timings := &Timings{}
+	result.Timings = timings
+
+	var d net.Dialer
+	start := time.Now()
+	conn, err := Dial(ctx, &d, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	timings.Connect = time.Since(start).Milliseconds()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if implicitTLS {
+		tlsStart := time.Now()
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		timings.TLS = time.Since(tlsStart).Milliseconds()
+		result.TLS = tlsInfo(tc.ConnectionState())
+		conn = tc
+	}
+
+	text := textproto.NewConn(conn)
+	greetStart := time.Now()
+	if err := p.greet(text); err != nil {
+		return err
+	}
+	timings.FirstByte = time.Since(greetStart).Milliseconds()
+
+	if target.StartTLS {
+		if err := p.startTLS(text); err != nil {
+			return err
+		}
+		tlsStart := time.Now()
+		tc := tls.Client(conn, tlsConfig)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		timings.TLS = time.Since(tlsStart).Milliseconds()
+		result.TLS = tlsInfo(tc.ConnectionState())
+		text = textproto.NewConn(tc)
+		if p.afterTLS != nil {
+			if err := p.afterTLS(text); err != nil {
+				return err
+			}
+		}
+	}
+
+	return p.quit(text)
+}
+
+func heloName() string {
+	return config.String("SMTP_HELO_NAME", "localhost")
+}
+
+var smtpProtocol = mailProtocol{
+	name:      "smtp",
+	plainPort: "25",
+	tlsPort:   "465",
+	greet: func(c *textproto.Conn) error {
+		_, _, err := c.ReadResponse(220)
+		return err
+	},
+	startTLS: func(c *textproto.Conn) error {
+		if err := smtpEHLO(c, true); err != nil {
+			return err
+		}
+		return smtpCmd(c, 220, "STARTTLS")
+	},
+	afterTLS: func(c *textproto.Conn) error {
+		return smtpEHLO(c, false)
+	},
+	quit: func(c *textproto.Conn) error {
+		return smtpCmd(c, 221, "QUIT")
+	},
+}
+
+// smtpEHLO greets the server. With requireTLS it also checks that the
+// server offers STARTTLS.
+func smtpEHLO(c *textproto.Conn, requireTLS bool) error {
+	id, err := c.Cmd("EHLO %s", heloName())
+	if err != nil {
+		return err
+	}
+	c.StartResponse(id)
+	defer c.EndResponse(id)
+	_, msg, err := c.ReadResponse(250)
+	if err != nil {
+		return err
+	}
+	if requireTLS && !slices.ContainsFunc(strings.Split(msg, "\n"), func(ext string) bool {
+		return strings.HasPrefix(strings.ToUpper(ext), "STARTTLS")
+	}) {
+		return fmt.Errorf("%w: STARTTLS not offered", errProtocol)
+	}
+	return nil
+}
+
+func smtpCmd(c *textproto.Conn, code int, cmd string) error {
+	id, err := c.Cmd("%s", cmd)
+	if err != nil {
+		return err
+	}
+	c.StartResponse(id)
+	defer c.EndResponse(id)
+	_, _, err = c.ReadResponse(code)
+	return err
+}
+
+var imapProtocol = mailProtocol{
+	name:      "imap",
+	plainPort: "143",
+	tlsPort:   "993",
+	greet: func(c *textproto.Conn) error {
+		line, err := c.ReadLine()
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "* OK") && !strings.HasPrefix(line, "* PREAUTH") {
+			return fmt.Errorf("%w: %q", errProtocol, line)
+		}
+		return nil
+	},
+	startTLS: func(c *textproto.Conn) error {
+		return imapCmd(c, "a1", "STARTTLS")
+	},
+	quit: func(c *textproto.Conn) error {
+		return imapCmd(c, "a2", "LOGOUT")
+	},
+}
+
+// imapCmd sends a tagged command and reads until its tagged response,
+// which must be OK.
+func imapCmd(c *textproto.Conn, tag, cmd string) error {
+	if err := c.PrintfLine("%s %s", tag, cmd); err != nil {
+		return err
+	}
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return err
+		}
+		if rest, ok := strings.CutPrefix(line, tag+" "); ok {
+			if !strings.HasPrefix(rest, "OK") {
+				return fmt.Errorf("%w: %q", errProtocol, line)
+			}
+			return nil
+		}
+	}
+}
+
+var pop3Protocol = mailProtocol{
+	name:      "pop3",
+	plainPort: "110",
+	tlsPort:   "995",
+	greet: func(c *textproto.Conn) error {
+		return pop3Reply(c)
+	},
+	startTLS: func(c *textproto.Conn) error {
+		if err := c.PrintfLine("STLS"); err != nil {
+			return err
+		}
+		return pop3Reply(c)
+	},
+	quit: func(c *textproto.Conn) error {
+		if err := c.PrintfLine("QUIT"); err != nil {
+			return err
+		}
+		return pop3Reply(c)
+	},
+}
+
+func pop3Reply(c *textproto.Conn) error {
+	line, err := c.ReadLine()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return fmt.Errorf("%w: %q", errProtocol, line)
+	}
+	return nil
+}
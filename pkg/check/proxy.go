@@ -0,0 +1,63 @@
+package check
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"monitor-workder/pkg/config"
+)
+
+type proxyKey struct{}
+
+// WithProxy returns a context whose outbound check requests go through
+// proxy instead of CHECK_PROXY_URL. An empty proxy leaves ctx unchanged.
+func WithProxy(ctx context.Context, proxy string) context.Context {
+	if proxy == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+// Proxy is the http.Transport Proxy function for outbound checks. It
+// uses the proxy set on the request's context by WithProxy, then
+// CHECK_PROXY_URL, then the standard HTTP_PROXY environment variables.
+// http, https and socks5 proxies are supported.
+func Proxy(req *http.Request) (*url.URL, error) {
+	raw, _ := req.Context().Value(proxyKey{}).(string)
+	if raw == "" {
+		raw = config.String("CHECK_PROXY_URL", "")
+	}
+	if raw == "" {
+		return http.ProxyFromEnvironment(req)
+	}
+	return parseProxy(raw)
+}
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("proxy URL %q has no host", raw)
+	}
+	return u, nil
+}
+
+// ValidateProxy reports whether target.Proxy, if set, is usable.
+func ValidateProxy(target Target) error {
+	if target.Proxy == "" {
+		return nil
+	}
+	if _, err := parseProxy(target.Proxy); err != nil {
+		return &TargetError{Field: "proxy", Message: "must be an http, https or socks5 URL"}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package check
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StatusCodes is a set of acceptable HTTP status codes, written as exact
+// codes ("204"), classes ("2xx") or inclusive ranges ("200-299"). In JSON it
+// may be an array of numbers and strings, or a single comma-separated
+// string such as "2xx,3xx".
+type StatusCodes []string
+
+func (s *StatusCodes) UnmarshalJSON(data []byte) error {
+	var spec string
+	if err := json.Unmarshal(data, &spec); err == nil {
+		*s = ParseStatusCodes(spec)
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("expectedStatusCodes must be an array or a string")
+	}
+
+	codes := make(StatusCodes, 0, len(items))
+	for _, item := range items {
+		var n int
+		if err := json.Unmarshal(item, &n); err == nil {
+			codes = append(codes, strconv.Itoa(n))
+			continue
+		}
+		var str string
+		if err := json.Unmarshal(item, &str); err != nil {
+			return fmt.Errorf("expectedStatusCodes entries must be numbers or strings")
+		}
+		codes = append(codes, strings.TrimSpace(str))
+	}
+	*s = codes
+	return nil
+}
+
+// ParseStatusCodes splits a comma-separated spec such as "200,3xx".
+func ParseStatusCodes(spec string) StatusCodes {
+	var codes StatusCodes
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			codes = append(codes, part)
+		}
+	}
+	return codes
+}
+
+// Validate reports the first entry that is not a valid code, class or
+// range.
+func (s StatusCodes) Validate() error {
+	for _, entry := range s {
+		if _, _, ok := bounds(entry); !ok {
+			return fmt.Errorf("%q is not a status code, class like 2xx, or range like 200-299", entry)
+		}
+	}
+	return nil
+}
+
+// Match reports whether code is acceptable. An empty set accepts any code.
+func (s StatusCodes) Match(code int) bool {
+	if len(s) == 0 {
+		return true
+	}
+	for _, entry := range s {
+		if lo, hi, ok := bounds(entry); ok && code >= lo && code <= hi {
+			return true
+		}
+	}
+	return false
+}
+
+func bounds(entry string) (lo, hi int, ok bool) {
+	entry = strings.ToLower(entry)
+
+	if len(entry) == 3 && strings.HasSuffix(entry, "xx") {
+		class, err := strconv.Atoi(entry[:1])
+		if err != nil || class < 1 || class > 5 {
+			return 0, 0, false
+		}
+		return class * 100, class*100 + 99, true
+	}
+
+	if from, to, found := strings.Cut(entry, "-"); found {
+		lo, errLo := strconv.Atoi(strings.TrimSpace(from))
+		hi, errHi := strconv.Atoi(strings.TrimSpace(to))
+		if errLo != nil || errHi != nil || !validCode(lo) || !validCode(hi) || lo > hi {
+			return 0, 0, false
+		}
+		return lo, hi, true
+	}
+
+	code, err := strconv.Atoi(entry)
+	if err != nil || !validCode(code) {
+		return 0, 0, false
+	}
+	return code, code, true
+}
+
+func validCode(code int) bool {
+	return code >= 100 && code <= 599
+}
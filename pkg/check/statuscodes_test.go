@@ -0,0 +1,81 @@
+package check
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestStatusCodesUnmarshal(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    StatusCodes
+		wantErr bool
+	}{
+		{raw: `"2xx, 3xx"`, want: StatusCodes{"2xx", "3xx"}},
+		{raw: `"200,,204"`, want: StatusCodes{"200", "204"}},
+		{raw: `[200, "3xx", " 400-404 "]`, want: StatusCodes{"200", "3xx", "400-404"}},
+		{raw: `[]`, want: StatusCodes{}},
+		{raw: `200`, wantErr: true},
+		{raw: `[true]`, wantErr: true},
+	}
+	for _, tt := range tests {
+		var got StatusCodes
+		err := json.Unmarshal([]byte(tt.raw), &got)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unmarshal(%s) = %v, want error", tt.raw, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, %v, want %v", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestStatusCodesMatch(t *testing.T) {
+	tests := []struct {
+		codes StatusCodes
+		code  int
+		want  bool
+	}{
+		{nil, 500, true},
+		{StatusCodes{"200"}, 200, true},
+		{StatusCodes{"200"}, 201, false},
+		{StatusCodes{"2xx"}, 299, true},
+		{StatusCodes{"2XX"}, 204, true},
+		{StatusCodes{"2xx"}, 300, false},
+		{StatusCodes{"200-204"}, 204, true},
+		{StatusCodes{"200 - 204"}, 202, true},
+		{StatusCodes{"200-204"}, 205, false},
+		{StatusCodes{"404", "5xx"}, 503, true},
+		{StatusCodes{"bogus"}, 200, false},
+	}
+	for _, tt := range tests {
+		if got := tt.codes.Match(tt.code); got != tt.want {
+			t.Errorf("%v.Match(%d) = %v, want %v", tt.codes, tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestStatusCodesValidate(t *testing.T) {
+	tests := []struct {
+		codes   StatusCodes
+		wantErr bool
+	}{
+		{StatusCodes{"200", "3xx", "400-499"}, false},
+		{StatusCodes{"6xx"}, true},
+		{StatusCodes{"0xx"}, true},
+		{StatusCodes{"99"}, true},
+		{StatusCodes{"600"}, true},
+		{StatusCodes{"300-200"}, true},
+		{StatusCodes{"200-"}, true},
+		{StatusCodes{"ok"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.codes.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%v.Validate() = %v, want error %v", tt.codes, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+// Package config reads worker settings from the environment and from an
+// optional config file named by CONFIG_FILE. A non-empty environment
+// variable takes precedence over the same setting in the file, so a
+// deployment can override a shared file per instance.
+package config
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Duration returns the duration stored in key, or def when it is unset or
+// not a valid Go duration string.
+func Duration(key string, def time.Duration) time.Duration {
+	v := lookup(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Invalid duration, using default")
+		return def
+	}
+	return d
+}
+
+// String returns the value of key, or def when it is unset.
+func String(key, def string) string {
+	if v := lookup(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// Int returns the integer stored in key, or def when it is unset or not a
+// valid integer.
+func Int(key string, def int) int {
+	v := lookup(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Warn().Err(err).Str("key", key).Msg("Invalid integer, using default")
+		return def
+	}
+	return n
+}
+
+// List returns the comma-separated values in key with surrounding spaces
+// trimmed and empty entries dropped, or nil when it is unset.
+func List(key string) []string {
+	var values []string
+	for _, v := range strings.Split(lookup(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// file holds the settings loaded from CONFIG_FILE, keyed like their
+// environment variables.
+var file atomic.Pointer[map[string]string]
+
+// lookup returns the value of key from the environment, falling back to
+// the config file when the variable is unset or empty.
+func lookup(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	if values := file.Load(); values != nil {
+		return (*values)[key]
+	}
+	return ""
+}
+
+// Load reads the YAML or JSON file named by CONFIG_FILE, replacing any
+// settings loaded before. It does nothing when CONFIG_FILE is unset.
+//
+// Keys are the environment variable names, matched case-insensitively.
+// Nested objects are joined with underscores, so {"rate_limit": {"rps": 5}}
+// sets RATE_LIMIT_RPS, and lists are joined with commas.
+func Load() error {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return nil
+	}
+	values, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	file.Store(&values)
+	return nil
+}
+
+// Watch reloads the config file on SIGHUP and whenever its modification
+// time changes, polling every CONFIG_POLL_INTERVAL, until ctx is done. A
+// file that fails to load is logged and the previous settings are kept.
+// Settings read once at startup, like DB_DRIVER, still need a restart, and
+// settings also set in the environment keep their environment value.
+func Watch(ctx context.Context) {
+	path := os.Getenv("CONFIG_FILE")
+	if path == "" {
+		return
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	poll := time.NewTicker(Duration("CONFIG_POLL_INTERVAL", 5*time.Second))
+	defer poll.Stop()
+
+	modified := modTime(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-poll.C:
+			if m := modTime(path); m.Equal(modified) {
+				continue
+			}
+		}
+		modified = modTime(path)
+		if err := Load(); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("Unable to reload config file, keeping previous settings")
+			continue
+		}
+		log.Info().Str("path", path).Msg("Reloaded config file")
+	}
+}
+
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		err = dec.Decode(&raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten(values, "", raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, raw map[string]any) error {
+	for k, v := range raw {
+		key := strings.ToUpper(strings.ReplaceAll(k, "-", "_"))
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+
+		switch v := v.(type) {
+		case map[string]any:
+			if err := flatten(values, key, v); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(v))
+			for i, item := range v {
+				s, err := scalar(item)
+				if err != nil {
+					return fmt.Errorf("%s: %w", key, err)
+				}
+				items[i] = s
+			}
+			values[key] = strings.Join(items, ",")
+		default:
+			s, err := scalar(v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			values[key] = s
+		}
+	}
+	return nil
+}
+
+func scalar(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number, bool, int, int64, uint64, float64:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", v)
+	}
+}
@@ -0,0 +1,31 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvironmentOverridesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("check_timeout: 10s\nrate_limit:\n  rps: 5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("CHECK_TIMEOUT", "3s")
+	t.Setenv("RATE_LIMIT_RPS", "")
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Store(nil) })
+
+	if got := String("CHECK_TIMEOUT", ""); got != "3s" {
+		t.Errorf("CHECK_TIMEOUT = %q, want the environment's 3s", got)
+	}
+	if got := Int("RATE_LIMIT_RPS", 0); got != 5 {
+		t.Errorf("RATE_LIMIT_RPS = %d, want the file's 5", got)
+	}
+	if got := String("UNSET_SETTING", "default"); got != "default" {
+		t.Errorf("UNSET_SETTING = %q, want the default", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package content detects unexpected changes to the pages the worker
+// checks. Each website's last accepted normalized body is kept as a
+// baseline, and results whose body moved away from it by more than the
+// target's tolerance are flagged with contentChanged, which catches
+// defacements and broken deploys that still answer 200.
+package content
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/storage"
+)
+
+var (
+	db      *sql.DB
+	dialect storage.Dialect
+)
+
+// Configure sets the database baselines are kept in. Until it is called
+// content is hashed but never compared.
+func Configure(conn *sql.DB, d storage.Dialect) {
+	db, dialect = conn, d
+}
+
+// Detect compares result's content with the website's baseline and sets
+// ContentChanged when it moved by more than the target's tolerance.
+//
+// The first body seen becomes the baseline, and it is replaced whenever a
+// change is flagged so that each change is reported once. Changes within
+// the tolerance keep the old baseline, so gradual drift is still flagged
+// once it adds up. Dry runs compare without touching the baseline.
+func Detect(ctx context.Context, target check.Target, result *check.Result) error {
+	if db == nil || target.Content == nil || result.Content == nil {
+		return nil
+	}
+	info := result.Content
+
+	hash, normalized, err := baseline(ctx, target)
+	if err != nil {
+		return err
+	}
+	if hash == info.Hash {
+		return nil
+	}
+	if hash != "" {
+		info.Change, info.Diff = Compare(normalized, info.Normalized)
+		if info.Change <= target.Content.Tolerance {
+			return nil
+		}
+		result.ContentChanged = true
+	}
+
+	if storage.DryRun(ctx) {
+		return nil
+	}
+	return save(ctx, target, info)
+}
+
+func baseline(ctx context.Context, target check.Target) (hash, normalized string, err error) {
+	err = db.QueryRowContext(ctx, dialect.Rebind(
+		`SELECT body_sha256, normalized FROM content_baselines WHERE website_id = $1`),
+		target.WebsiteID.String()).Scan(&hash, &normalized)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", "", nil
+	}
+	return hash, normalized, err
+}
+
+func save(ctx context.Context, target check.Target, info *check.ContentInfo) error {
+	upsert := `INSERT INTO content_baselines (website_id, body_sha256, normalized, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (website_id) DO UPDATE SET body_sha256 = excluded.body_sha256,
+			normalized = excluded.normalized, updated_at = excluded.updated_at`
+	if dialect == storage.MySQL {
+		upsert = `INSERT INTO content_baselines (website_id, body_sha256, normalized, updated_at) VALUES ($1, $2, $3, $4)
+			ON DUPLICATE KEY UPDATE body_sha256 = VALUES(body_sha256),
+				normalized = VALUES(normalized), updated_at = VALUES(updated_at)`
+	}
+
+	_, err := db.ExecContext(ctx, dialect.Rebind(upsert),
+		target.WebsiteID.String(), info.Hash, info.Normalized, dialect.Time(time.Now()))
+	return err
+}
+
+// Compare returns the fraction of lines added or removed between two
+// normalized bodies, ignoring moves, along with a snippet of the removed
+// and added lines of at most CONTENT_DIFF_BYTES.
+func Compare(before, after string) (float64, string) {
+	if before == after {
+		return 0, ""
+	}
+	oldLines, newLines := lines(before), lines(after)
+
+	remaining := make(map[string]int, len(oldLines))
+	for _, l := range oldLines {
+		remaining[l]++
+	}
+	var added, removed []string
+	for _, l := range newLines {
+		if remaining[l] > 0 {
+			remaining[l]--
+		} else {
+			added = append(added, l)
+		}
+	}
+	for _, l := range oldLines {
+		if remaining[l] > 0 {
+			remaining[l]--
+			removed = append(removed, l)
+		}
+	}
+
+	change := float64(len(added)+len(removed)) / float64(len(oldLines)+len(newLines))
+	return change, snippet(removed, added, config.Int("CONTENT_DIFF_BYTES", 1024))
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// maxLineRunes shortens long lines in snippets, such as minified scripts.
+const maxLineRunes = 120
+
+// snippet lists removed then added lines within limit bytes. Removed
+// lines get at most half of it when there are added lines to show too.
+func snippet(removed, added []string, limit int) string {
+	var b strings.Builder
+	write := func(prefix string, ls []string, limit int) {
+		for _, l := range ls {
+			if r := []rune(l); len(r) > maxLineRunes {
+				l = string(r[:maxLineRunes]) + "…"
+			}
+			line := prefix + l + "\n"
+			if b.Len()+len(line) > limit {
+				return
+			}
+			b.WriteString(line)
+		}
+	}
+	if len(added) > 0 {
+		write("- ", removed, limit/2)
+	} else {
+		write("- ", removed, limit)
+	}
+	write("+ ", added, limit)
+	return strings.TrimSuffix(b.String(), "\n")
+}
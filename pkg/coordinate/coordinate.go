@@ -0,0 +1,154 @@
+// Package coordinate fans a check batch out to peer workers in other
+// regions with a shared executeAt, so every region probes the targets at
+// the same moment and their latencies can be compared directly.
+//
+// Peers are listed in PEER_WORKERS as "region=url" pairs, where url is the
+// peer's base URL. Requests to peers are signed with the HMAC key named by
+// COORDINATOR_KEY_ID, which every peer must also have in HMAC_KEYS.
+package coordinate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/auth"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/tracing"
+	"monitor-workder/pkg/worker"
+)
+
+var ErrNoPeers = errors.New("no peer workers configured")
+
+type Peer struct {
+	Region string
+	URL    string
+}
+
+// Peers returns the peers configured in PEER_WORKERS, skipping malformed
+// entries.
+func Peers() []Peer {
+	var peers []Peer
+	for _, pair := range config.List("PEER_WORKERS") {
+		region, url, found := strings.Cut(pair, "=")
+		if found && region != "" && url != "" {
+			peers = append(peers, Peer{Region: region, URL: strings.TrimSuffix(url, "/")})
+		}
+	}
+	return peers
+}
+
+// Request is a batch to run on every peer. Targets are passed through
+// untouched and validated by each peer.
+type Request struct {
+	Urls       []json.RawMessage `json:"urls"`
+	CheckRunID string            `json:"checkRunId,omitempty"`
+}
+
+type Response struct {
+	ExecuteAt time.Time      `json:"executeAt"`
+	Regions   []RegionResult `json:"regions"`
+}
+
+// RegionResult is one peer's response. Results holds the peer's response
+// body as-is when it succeeded; otherwise Error explains what went wrong.
+type RegionResult struct {
+	Region     string          `json:"region"`
+	StatusCode int             `json:"statusCode,omitempty"`
+	Results    json.RawMessage `json:"results,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Fanout sends req to every peer with an executeAt COORDINATION_LEAD from
+// now and waits for all of them. Each peer gets its own check run ID,
+// derived from req's, so peers sharing a database do not collide. Peers
+// see the coordinator's key rather than caller's, so the ID is scoped to
+// caller's tenant first, as the worker itself does for idempotency keys,
+// and a tenant's batch is marked Guarded.
+func Fanout(ctx context.Context, caller auth.Caller, req Request) (Response, error) {
+	peers := Peers()
+	if len(peers) == 0 {
+		return Response{}, ErrNoPeers
+	}
+	keyID := config.String("COORDINATOR_KEY_ID", "")
+	if keyID == "" {
+		return Response{}, errors.New("COORDINATOR_KEY_ID is not set")
+	}
+
+	lead := config.Duration("COORDINATION_LEAD", 2*time.Second)
+	executeAt := time.Now().Add(lead).UTC()
+
+	ctx, cancel := context.WithTimeout(ctx, lead+worker.CheckTimeout()+5*time.Second)
+	defer cancel()
+
+	resp := Response{ExecuteAt: executeAt, Regions: make([]RegionResult, len(peers))}
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			body := worker.Request{Region: peer.Region, ExecuteAt: &executeAt, Guarded: caller.TenantID != ""}
+			if req.CheckRunID != "" {
+				body.CheckRunID = caller.IdempotencyKey(req.CheckRunID) + "/" + peer.Region
+			}
+			resp.Regions[i] = send(ctx, peer, keyID, body, req.Urls)
+		}()
+	}
+	wg.Wait()
+	return resp, nil
+}
+
+func send(ctx context.Context, peer Peer, keyID string, req worker.Request, urls []json.RawMessage) RegionResult {
+	result := RegionResult{Region: peer.Region}
+
+	body, err := json.Marshal(struct {
+		worker.Request
+		Urls []json.RawMessage `json:"urls"`
+	}{req, urls})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, peer.URL+"/", bytes.NewReader(body))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if req.Guarded {
+		// A peer that predates the field would run the batch unguarded.
+		httpReq.Header.Set("X-Require-Features", "guarded")
+	}
+	tracing.Inject(ctx, httpReq)
+	if err := auth.SignRequest(httpReq, keyID, body); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	httpResp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	result.StatusCode = httpResp.StatusCode
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case httpResp.StatusCode != http.StatusOK:
+		result.Error = fmt.Sprintf("peer returned %s: %s", httpResp.Status, bytes.TrimSpace(data))
+	default:
+		result.Results = data
+	}
+	return result
+}
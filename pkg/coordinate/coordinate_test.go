@@ -0,0 +1,59 @@
+package coordinate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"monitor-workder/pkg/auth"
+)
+
+func TestFanoutScopesTenantBatches(t *testing.T) {
+	type forwarded struct {
+		CheckRunID string `json:"checkRunId"`
+		Guarded    bool   `json:"guarded"`
+	}
+	received := make(chan forwarded, 1)
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := auth.Authenticate(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		var body forwarded
+		json.NewDecoder(r.Body).Decode(&body)
+		received <- body
+		w.Write([]byte("[]"))
+	}))
+	defer peer.Close()
+
+	t.Setenv("PEER_WORKERS", "eu="+peer.URL)
+	t.Setenv("COORDINATOR_KEY_ID", "coord")
+	t.Setenv("HMAC_KEYS", "coord:secret")
+	t.Setenv("COORDINATION_LEAD", "1ms")
+
+	tests := []struct {
+		name   string
+		caller auth.Caller
+		want   forwarded
+	}{
+		{"operator", auth.Caller{KeyID: "ops"}, forwarded{"run-1/eu", false}},
+		{"tenant a", auth.Caller{KeyID: "a", TenantID: "tenant-a"}, forwarded{"tenant:tenant-a/run-1/eu", true}},
+		{"tenant b", auth.Caller{KeyID: "b", TenantID: "tenant-b"}, forwarded{"tenant:tenant-b/run-1/eu", true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := Fanout(context.Background(), tt.caller, Request{CheckRunID: "run-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if region := resp.Regions[0]; region.Error != "" {
+				t.Fatalf("peer error: %s", region.Error)
+			}
+			if got := <-received; got != tt.want {
+				t.Errorf("peer got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
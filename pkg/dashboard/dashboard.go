@@ -0,0 +1,166 @@
+// Package dashboard serves a minimal read-only HTML dashboard for
+// self-hosted workers: the latest status of every website checked in the
+// last day, recent incidents and the worker's own health.
+//
+// The dashboard is disabled unless DASHBOARD_PASSWORD is set. Browsers
+// authenticate with HTTP basic auth as DASHBOARD_USER (default "admin").
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"html/template"
+	"net/http"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/storage"
+	"monitor-workder/pkg/version"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var page = template.Must(template.ParseFS(templates, "templates/*.html"))
+
+type Dashboard struct {
+	DB      *sql.DB
+	Dialect storage.Dialect
+}
+
+type Status struct {
+	WebsiteID    string
+	Status       string
+	StatusCode   int
+	ResponseTime int64
+	CheckedAt    string
+}
+
+type Incident struct {
+	WebsiteID  string
+	Region     string
+	URL        string
+	StartedAt  string
+	ResolvedAt string
+	Downtime   time.Duration
+	Status     string
+	StatusCode int
+}
+
+type Health struct {
+	Version   version.Info
+	DBLatency time.Duration
+	DBError   string
+}
+
+type data struct {
+	Statuses  []Status
+	Incidents []Incident
+	Health    Health
+	Errors    []string
+}
+
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	password := config.String("DASHBOARD_PASSWORD", "")
+	if password == "" {
+		http.NotFound(w, r)
+		return
+	}
+	user, pass, ok := r.BasicAuth()
+	if !ok ||
+		subtle.ConstantTimeCompare([]byte(user), []byte(config.String("DASHBOARD_USER", "admin"))) != 1 ||
+		subtle.ConstantTimeCompare([]byte(pass), []byte(password)) != 1 {
+		w.Header().Set("WWW-Authenticate", `Basic realm="dashboard"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := r.Context()
+	var v data
+	v.Health = d.health(ctx)
+
+	var err error
+	if v.Statuses, err = d.statuses(ctx); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Error reading statuses for dashboard")
+		v.Errors = append(v.Errors, "Unable to read current statuses")
+	}
+	if v.Incidents, err = d.incidents(ctx); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Error reading incidents for dashboard")
+		v.Errors = append(v.Errors, "Unable to read incidents")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := page.ExecuteTemplate(w, "index.html", v); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Error rendering dashboard")
+	}
+}
+
+func (d *Dashboard) health(ctx context.Context) Health {
+	h := Health{Version: version.Get()}
+	start := time.Now()
+	if err := d.DB.PingContext(ctx); err != nil {
+		h.DBError = err.Error()
+	}
+	h.DBLatency = time.Since(start).Round(time.Millisecond)
+	return h
+}
+
+// statuses returns the latest result of each website checked in the last
+// day.
+func (d *Dashboard) statuses(ctx context.Context) ([]Status, error) {
+	rows, err := d.DB.QueryContext(ctx, d.Dialect.Rebind(
+		`SELECT u.website_id, u.status, u.status_code, u.response_time, `+d.Dialect.TimeColumn("u.checked_at")+`
+		FROM uptime_checks u
+		JOIN (
+			SELECT website_id, MAX(checked_at) AS checked_at FROM uptime_checks
+			WHERE checked_at >= $1 GROUP BY website_id
+		) latest ON u.website_id = latest.website_id AND u.checked_at = latest.checked_at
+		ORDER BY u.status, u.website_id`),
+		d.Dialect.Time(time.Now().Add(-24*time.Hour)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []Status
+	for rows.Next() {
+		var s Status
+		if err := rows.Scan(&s.WebsiteID, &s.Status, &s.StatusCode, &s.ResponseTime, &s.CheckedAt); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+// incidents returns the most recently started incidents that were opened.
+func (d *Dashboard) incidents(ctx context.Context) ([]Incident, error) {
+	rows, err := d.DB.QueryContext(ctx,
+		`SELECT website_id, region, coalesce(url, ''), `+d.Dialect.TimeColumn("started_at")+`,
+			coalesce(`+d.Dialect.TimeColumn("resolved_at")+`, ''), coalesce(downtime_ms, 0),
+			coalesce(status, ''), coalesce(status_code, 0)
+		FROM incidents WHERE opened_at IS NOT NULL
+		ORDER BY started_at DESC LIMIT 20`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var incidents []Incident
+	for rows.Next() {
+		var (
+			i          Incident
+			downtimeMs int64
+		)
+		if err := rows.Scan(&i.WebsiteID, &i.Region, &i.URL, &i.StartedAt, &i.ResolvedAt, &downtimeMs, &i.Status, &i.StatusCode); err != nil {
+			return nil, err
+		}
+		i.Downtime = time.Duration(downtimeMs) * time.Millisecond
+		incidents = append(incidents, i)
+	}
+	return incidents, rows.Err()
+}
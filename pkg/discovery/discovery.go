@@ -0,0 +1,24 @@
+// Package discovery defines the Discoverer extension point, which supplies
+// check targets from an external inventory instead of the request body.
+package discovery
+
+import (
+	"context"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/plugin"
+)
+
+type Discoverer interface {
+	Discover(ctx context.Context) ([]check.Target, error)
+}
+
+var registry = plugin.NewRegistry[Discoverer]("discovery")
+
+func Register(name string, d Discoverer) {
+	registry.Register(name, d)
+}
+
+func Lookup(name string) (Discoverer, bool) {
+	return registry.Lookup(name)
+}
@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+)
+
+// Postgres discovers monitors from the websites table. Each call claims up
+// to Limit sites whose next_check_at has passed and pushes their
+// next_check_at forward by their check_interval, so concurrent schedulers
+// never pick up the same site twice. Paused sites are skipped.
+type Postgres struct {
+	DB    *sql.DB
+	Limit int
+}
+
+func (p *Postgres) Discover(ctx context.Context) ([]check.Target, error) {
+	rows, err := p.DB.QueryContext(ctx,
+		`UPDATE websites SET next_check_at = now() + make_interval(secs => check_interval)
+		WHERE id IN (
+			SELECT id FROM websites
+			WHERE NOT paused AND (next_check_at IS NULL OR next_check_at <= now())
+			ORDER BY next_check_at NULLS FIRST
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, url, coalesce(check_type, ''), coalesce(script, ''), check_interval, confirmations`,
+		p.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var targets []check.Target
+	for rows.Next() {
+		var t check.Target
+		if err := rows.Scan(&t.WebsiteID, &t.URL, &t.CheckType, &t.Script, &t.IntervalSeconds, &t.Confirmations); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, rows.Err()
+}
+
+// Defer pushes the next check of websiteID back to at least until, so
+// targets that asked for a back-off via Retry-After are not hit again early.
+func (p *Postgres) Defer(ctx context.Context, websiteID uuid.UUID, until time.Time) error {
+	_, err := p.DB.ExecContext(ctx,
+		`UPDATE websites SET next_check_at = greatest(coalesce(next_check_at, now()), $2)
+		WHERE id = $1`,
+		websiteID, until)
+	return err
+}
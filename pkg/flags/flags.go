@@ -0,0 +1,50 @@
+// Package flags implements percentage rollouts for new code paths.
+//
+// Flags are configured in FEATURE_FLAGS as comma-separated name=percent
+// pairs, e.g. "http_engine_v2=10". Each key (usually a website ID) hashes
+// into a fixed bucket, so a site stays on the same side of a flag as its
+// percentage is raised and results can be compared per site.
+package flags
+
+import (
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"monitor-workder/pkg/config"
+)
+
+const HTTPEngineV2 = "http_engine_v2"
+
+// Percentage returns the rollout percentage of flag, from 0 to 100.
+func Percentage(flag string) int {
+	for _, entry := range config.List("FEATURE_FLAGS") {
+		name, value, found := strings.Cut(entry, "=")
+		if !found || strings.TrimSpace(name) != flag {
+			continue
+		}
+		p, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0
+		}
+		return min(max(p, 0), 100)
+	}
+	return 0
+}
+
+// Enabled reports whether flag is on for key.
+func Enabled(flag, key string) bool {
+	p := Percentage(flag)
+	if p <= 0 {
+		return false
+	}
+	if p >= 100 {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32()%100) < p
+}
@@ -0,0 +1,190 @@
+// Package heartbeat implements push-based monitors for jobs that cannot be
+// probed from outside, such as cron jobs and queue consumers. The job pings
+// POST /heartbeat/{monitorId} whenever it runs, and a "heartbeat" check on
+// the same monitor reports "down" once no ping has arrived within the
+// target's interval plus HEARTBEAT_GRACE. A monitor that has not pinged yet
+// gets the same window from when it was registered.
+//
+// The check runs on the normal schedule like any other monitor, so the
+// scheduler's periodic pass over due monitors doubles as the sweep for
+// overdue heartbeats and their results are stored and alerted on as usual.
+// Pings are only accepted for registered monitors, so the unauthenticated
+// ping endpoint cannot be used to fill the database with arbitrary IDs.
+// Each check registers its monitor, and on Postgres a websites row with
+// check_type 'heartbeat' counts as registered from the moment it is
+// created, so a job can ping before the monitor's first check.
+package heartbeat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/outcome"
+	"monitor-workder/pkg/storage"
+)
+
+var (
+	db      *sql.DB
+	dialect storage.Dialect
+)
+
+func init() {
+	check.Register("heartbeat", checker{})
+}
+
+// Configure sets the database heartbeats are recorded in. Until it is
+// called pings are rejected and heartbeat checks report "down".
+func Configure(conn *sql.DB, d storage.Dialect) {
+	db, dialect = conn, d
+}
+
+var (
+	ErrNotConfigured  = errors.New("heartbeat storage is not configured")
+	ErrUnknownMonitor = errors.New("unknown heartbeat monitor")
+)
+
+// Record stores a ping for monitorID at the current time. It returns
+// ErrUnknownMonitor unless monitorID is a registered heartbeat monitor.
+func Record(ctx context.Context, monitorID uuid.UUID) error {
+	if db == nil {
+		return ErrNotConfigured
+	}
+
+	// Only Postgres has a websites table with check types; self-hosted
+	// workers rely on registration by the first check.
+	query := `SELECT count(*) FROM heartbeat_monitors WHERE monitor_id = $1`
+	if dialect == storage.Postgres {
+		query = `SELECT (SELECT count(*) FROM heartbeat_monitors WHERE monitor_id = $1)
+			+ (SELECT count(*) FROM websites WHERE id = $1 AND check_type = 'heartbeat')`
+	}
+
+	var registered int
+	err := db.QueryRowContext(ctx, dialect.Rebind(query), monitorID.String()).Scan(&registered)
+	if err != nil {
+		return err
+	}
+	if registered == 0 {
+		return ErrUnknownMonitor
+	}
+
+	upsert := `INSERT INTO heartbeats (monitor_id, last_ping_at) VALUES ($1, $2)
+		ON CONFLICT (monitor_id) DO UPDATE SET last_ping_at = excluded.last_ping_at`
+	if dialect == storage.MySQL {
+		upsert = `INSERT INTO heartbeats (monitor_id, last_ping_at) VALUES ($1, $2)
+			ON DUPLICATE KEY UPDATE last_ping_at = VALUES(last_ping_at)`
+	}
+
+	_, err = db.ExecContext(ctx, dialect.Rebind(upsert), monitorID.String(), dialect.Time(time.Now()))
+	return err
+}
+
+// register records monitorID as a heartbeat monitor whose pings Record
+// accepts.
+func register(ctx context.Context, monitorID uuid.UUID) error {
+	insert := `INSERT INTO heartbeat_monitors (monitor_id, registered_at) VALUES ($1, $2)
+		ON CONFLICT (monitor_id) DO NOTHING`
+	if dialect == storage.MySQL {
+		insert = `INSERT IGNORE INTO heartbeat_monitors (monitor_id, registered_at) VALUES ($1, $2)`
+	}
+	_, err := db.ExecContext(ctx, dialect.Rebind(insert), monitorID.String(), dialect.Time(time.Now()))
+	return err
+}
+
+// registeredAt returns when monitorID was registered, or the zero time if
+// it has not been.
+func registeredAt(ctx context.Context, monitorID uuid.UUID) (time.Time, error) {
+	var raw string
+	err := db.QueryRowContext(ctx, dialect.Rebind(
+		`SELECT `+dialect.TimeColumn("registered_at")+` FROM heartbeat_monitors WHERE monitor_id = $1`), monitorID.String()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return storage.ParseTime(raw)
+}
+
+// LastPing returns when monitorID last pinged, or the zero time if it
+// never has.
+func LastPing(ctx context.Context, monitorID uuid.UUID) (time.Time, error) {
+	if db == nil {
+		return time.Time{}, ErrNotConfigured
+	}
+
+	var raw string
+	err := db.QueryRowContext(ctx, dialect.Rebind(
+		`SELECT `+dialect.TimeColumn("last_ping_at")+` FROM heartbeats WHERE monitor_id = $1`), monitorID.String()).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, raw)
+}
+
+type checker struct{}
+
+func (checker) Validate(target check.Target) error {
+	if target.IntervalSeconds <= 0 {
+		return &check.TargetError{Field: "intervalSeconds", Message: "is required for heartbeat checks"}
+	}
+	return nil
+}
+
+// Check reports "up" if the monitor pinged within its interval plus
+// grace, or has never pinged but was registered within that window. The
+// response time is the age of the last ping.
+func (checker) Check(ctx context.Context, target check.Target) check.Result {
+	result := check.Result{
+		WebsiteID:     target.WebsiteID,
+		URL:           target.URL,
+		Status:        "down",
+		FailureReason: outcome.ReasonHeartbeatMissed,
+	}
+
+	if db != nil && !storage.DryRun(ctx) {
+		if err := register(ctx, target.WebsiteID); err != nil {
+			logging.From(ctx).Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Error registering heartbeat monitor")
+		}
+	}
+
+	last, err := LastPing(ctx, target.WebsiteID)
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Error reading heartbeat")
+		return result
+	}
+
+	deadline := time.Duration(target.IntervalSeconds)*time.Second + config.Duration("HEARTBEAT_GRACE", time.Minute)
+	if last.IsZero() {
+		if db == nil {
+			return result
+		}
+		registered, err := registeredAt(ctx, target.WebsiteID)
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Error reading heartbeat registration")
+			return result
+		}
+		if !registered.IsZero() && time.Since(registered) <= deadline {
+			result.Status = "up"
+			result.FailureReason = ""
+		}
+		return result
+	}
+
+	age := time.Since(last)
+	result.ResponseTime = age.Milliseconds()
+	if age <= deadline {
+		result.Status = "up"
+		result.FailureReason = ""
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package heartbeat
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/storage"
+)
+
+func TestRecordRequiresRegisteredMonitor(t *testing.T) {
+	conn, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	defer conn.Close()
+	for _, name := range []string{"0007_heartbeats.sql", "0016_heartbeat_monitors.sql"} {
+		migration, err := os.ReadFile("../../migrations/sqlite/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	Configure(conn, storage.SQLite)
+	defer Configure(nil, "")
+
+	ctx := context.Background()
+	target := check.Target{WebsiteID: uuid.New(), CheckType: "heartbeat", IntervalSeconds: 60}
+
+	if err := Record(ctx, target.WebsiteID); !errors.Is(err, ErrUnknownMonitor) {
+		t.Fatalf("Record() before registration = %v, want ErrUnknownMonitor", err)
+	}
+	if result := (checker{}).Check(ctx, target); result.Status != "up" {
+		t.Errorf("Check() without pings inside the grace window = %q, want up", result.Status)
+	}
+	if _, err := conn.Exec(`UPDATE heartbeat_monitors SET registered_at = ?`,
+		storage.SQLite.Time(time.Now().Add(-time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	if result := (checker{}).Check(ctx, target); result.Status != "down" {
+		t.Errorf("Check() without pings past the grace window = %q, want down", result.Status)
+	}
+
+	if err := Record(ctx, target.WebsiteID); err != nil {
+		t.Fatalf("Record() after registration = %v", err)
+	}
+	if err := Record(ctx, target.WebsiteID); err != nil {
+		t.Fatalf("second Record() = %v", err)
+	}
+	if result := (checker{}).Check(ctx, target); result.Status != "up" {
+		t.Errorf("Check() after ping = %q, want up", result.Status)
+	}
+
+	var rows int
+	if err := conn.QueryRow(`SELECT count(*) FROM heartbeats`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("heartbeats has %d rows, want 1", rows)
+	}
+}
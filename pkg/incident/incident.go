@@ -0,0 +1,135 @@
+// Package incident turns streams of check results into incident rows.
+//
+// Each website and region has at most one active row, enforced by a unique
+// index on active incidents. The first "down" result starts one; once
+// INCIDENT_THRESHOLD consecutive failures have been seen it is opened, and
+// the next "up" or "degraded" result resolves it and records the downtime.
+// A streak that recovers before reaching the threshold is discarded.
+// Throttled results neither extend nor end a streak.
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/notify"
+	"monitor-workder/pkg/storage"
+)
+
+var (
+	db      *sql.DB
+	dialect storage.Dialect
+)
+
+func init() {
+	notify.Register("incidents", tracker{})
+}
+
+// Configure sets the database incidents are tracked in. Until it is
+// called results are ignored.
+func Configure(conn *sql.DB, d storage.Dialect) {
+	db, dialect = conn, d
+}
+
+func threshold() int {
+	return max(config.Int("INCIDENT_THRESHOLD", 3), 1)
+}
+
+type tracker struct{}
+
+func (tracker) Notify(ctx context.Context, event notify.Event) error {
+	if db == nil {
+		return nil
+	}
+	switch event.Result.Status {
+	case "down":
+		return fail(ctx, event)
+	case "up", "degraded":
+		return resolve(ctx, event)
+	}
+	return nil
+}
+
+type active struct {
+	id        int64
+	startedAt time.Time
+	opened    bool
+}
+
+func find(ctx context.Context, event notify.Event) (*active, error) {
+	var (
+		a         active
+		startedAt string
+		openedAt  sql.NullString
+	)
+	err := db.QueryRowContext(ctx, dialect.Rebind(
+		`SELECT id, `+dialect.TimeColumn("started_at")+`, `+dialect.TimeColumn("opened_at")+`
+		FROM incidents WHERE website_id = $1 AND region = $2 AND resolved_at IS NULL`),
+		event.Result.WebsiteID.String(), event.Region).Scan(&a.id, &startedAt, &openedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if a.startedAt, err = time.Parse(time.RFC3339Nano, startedAt); err != nil {
+		return nil, err
+	}
+	a.opened = openedAt.Valid
+	return &a, nil
+}
+
+// fail starts an incident or extends the active one in a single upsert,
+// so concurrent failures from parallel batches or regions cannot open two.
+// The failing check's details are kept up to date so an open incident
+// shows the most recent failure.
+func fail(ctx context.Context, event notify.Event) error {
+	r := event.Result
+	var openedAt any
+	if threshold() <= 1 {
+		openedAt = dialect.Time(r.CheckedAt)
+	}
+
+	upsert := `INSERT INTO incidents (website_id, region, url, started_at, opened_at, failures, status, status_code)
+		VALUES ($1, $2, $3, $4, $5, 1, $6, $7)
+		ON CONFLICT (website_id, region) WHERE resolved_at IS NULL DO UPDATE SET
+			opened_at = CASE WHEN incidents.opened_at IS NULL AND incidents.failures + 1 >= $8
+				THEN excluded.started_at ELSE incidents.opened_at END,
+			failures = incidents.failures + 1, status = excluded.status, status_code = excluded.status_code`
+	if dialect == storage.MySQL {
+		// Assignments are applied left to right, so opened_at must read
+		// failures before it is incremented.
+		upsert = `INSERT INTO incidents (website_id, region, url, started_at, opened_at, failures, status, status_code)
+			VALUES ($1, $2, $3, $4, $5, 1, $6, $7)
+			ON DUPLICATE KEY UPDATE
+				opened_at = CASE WHEN opened_at IS NULL AND failures + 1 >= $8
+					THEN VALUES(started_at) ELSE opened_at END,
+				failures = failures + 1, status = VALUES(status), status_code = VALUES(status_code)`
+	}
+
+	_, err := db.ExecContext(ctx, dialect.Rebind(upsert),
+		r.WebsiteID.String(), event.Region, r.URL, dialect.Time(r.CheckedAt), openedAt, r.Status, r.StatusCode,
+		threshold())
+	return err
+}
+
+func resolve(ctx context.Context, event notify.Event) error {
+	a, err := find(ctx, event)
+	if err != nil || a == nil {
+		return err
+	}
+
+	if !a.opened {
+		_, err = db.ExecContext(ctx, dialect.Rebind(`DELETE FROM incidents WHERE id = $1`), a.id)
+		return err
+	}
+
+	resolvedAt := event.Result.CheckedAt
+	_, err = db.ExecContext(ctx, dialect.Rebind(
+		`UPDATE incidents SET resolved_at = $1, downtime_ms = $2 WHERE id = $3`),
+		dialect.Time(resolvedAt), resolvedAt.Sub(a.startedAt).Milliseconds(), a.id)
+	return err
+}
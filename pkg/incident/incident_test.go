@@ -0,0 +1,155 @@
+package incident
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/notify"
+	"monitor-workder/pkg/storage"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	for _, name := range []string{"0008_incidents.sql", "0015_incidents_active_unique.sql"} {
+		migration, err := os.ReadFile("../../migrations/sqlite/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	Configure(conn, storage.SQLite)
+	t.Cleanup(func() { Configure(nil, "") })
+	return conn
+}
+
+type incidentRow struct {
+	failures int
+	opened   bool
+	resolved bool
+}
+
+func TestTracker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold string
+		statuses  []string
+		want      []incidentRow
+	}{
+		{
+			name:      "streak below threshold is discarded",
+			threshold: "3",
+			statuses:  []string{"down", "down", "up"},
+			want:      nil,
+		},
+		{
+			name:      "streak reaching threshold opens",
+			threshold: "3",
+			statuses:  []string{"down", "down", "down"},
+			want:      []incidentRow{{failures: 3, opened: true}},
+		},
+		{
+			name:      "recovery resolves an open incident",
+			threshold: "2",
+			statuses:  []string{"down", "down", "degraded"},
+			want:      []incidentRow{{failures: 2, opened: true, resolved: true}},
+		},
+		{
+			name:      "throttled neither extends nor ends a streak",
+			threshold: "2",
+			statuses:  []string{"down", "throttled", "down"},
+			want:      []incidentRow{{failures: 2, opened: true}},
+		},
+		{
+			name:      "threshold of one opens immediately",
+			threshold: "1",
+			statuses:  []string{"down"},
+			want:      []incidentRow{{failures: 1, opened: true}},
+		},
+		{
+			name:      "new failure after resolution starts a new incident",
+			threshold: "1",
+			statuses:  []string{"down", "up", "down"},
+			want:      []incidentRow{{failures: 1, opened: true, resolved: true}, {failures: 1, opened: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := openTestDB(t)
+			t.Setenv("INCIDENT_THRESHOLD", tt.threshold)
+
+			websiteID := uuid.New()
+			checkedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			for _, status := range tt.statuses {
+				event := notify.Event{Region: "eu", Result: check.Result{
+					WebsiteID: websiteID,
+					Status:    status,
+					CheckedAt: checkedAt,
+				}}
+				if err := (tracker{}).Notify(context.Background(), event); err != nil {
+					t.Fatalf("Notify(%s): %v", status, err)
+				}
+				checkedAt = checkedAt.Add(time.Minute)
+			}
+
+			rows, err := conn.Query(`SELECT failures, opened_at IS NOT NULL, resolved_at IS NOT NULL FROM incidents ORDER BY id`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []incidentRow
+			for rows.Next() {
+				var r incidentRow
+				if err := rows.Scan(&r.failures, &r.opened, &r.resolved); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, r)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d incidents %+v, want %+v", len(got), got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("incident %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFailIsSingleActiveIncident(t *testing.T) {
+	conn := openTestDB(t)
+	t.Setenv("INCIDENT_THRESHOLD", "5")
+
+	event := notify.Event{Result: check.Result{WebsiteID: uuid.New(), Status: "down", CheckedAt: time.Now()}}
+	for range 3 {
+		if err := fail(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count, failures int
+	if err := conn.QueryRow(`SELECT count(*), max(failures) FROM incidents WHERE resolved_at IS NULL`).Scan(&count, &failures); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 || failures != 3 {
+		t.Errorf("got %d active incidents with %d failures, want 1 with 3", count, failures)
+	}
+}
@@ -0,0 +1,72 @@
+// Package logging sets up the worker's structured JSON logs and carries
+// correlation IDs through contexts. Every invocation (an HTTP request, a
+// queue message or a scheduler run) has a request ID and every target
+// checked in it has a check ID; loggers from From include both, plus the
+// trace ID when tracing is on.
+package logging
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/tracing"
+)
+
+// Configure sets the minimum level logged from LOG_LEVEL (trace, debug,
+// info, warn or error), defaulting to info.
+func Configure() {
+	level, err := zerolog.ParseLevel(strings.ToLower(config.String("LOG_LEVEL", "info")))
+	if err != nil || level == zerolog.NoLevel {
+		log.Warn().Err(err).Msg("Invalid LOG_LEVEL, using info")
+		level = zerolog.InfoLevel
+	}
+	zerolog.SetGlobalLevel(level)
+}
+
+type (
+	requestIDKey struct{}
+	checkIDKey   struct{}
+)
+
+// WithRequestID returns ctx carrying the invocation's request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID carried by ctx, or "".
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithCheckID returns ctx carrying the ID of a single target's check.
+func WithCheckID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, checkIDKey{}, id)
+}
+
+// CheckID returns the check ID carried by ctx, or "".
+func CheckID(ctx context.Context) string {
+	id, _ := ctx.Value(checkIDKey{}).(string)
+	return id
+}
+
+// From returns the global logger tagged with ctx's request, check and
+// trace IDs, leaving out any that are unset.
+func From(ctx context.Context) *zerolog.Logger {
+	c := log.With()
+	if id := RequestID(ctx); id != "" {
+		c = c.Str("requestId", id)
+	}
+	if id := CheckID(ctx); id != "" {
+		c = c.Str("checkId", id)
+	}
+	if id := tracing.TraceID(ctx); id != "" {
+		c = c.Str("traceId", id)
+	}
+	l := c.Logger()
+	return &l
+}
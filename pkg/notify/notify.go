@@ -0,0 +1,37 @@
+// Package notify defines the Notifier extension point, which is told about
+// every check result and decides for itself whether to alert on it.
+package notify
+
+import (
+	"context"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/plugin"
+)
+
+type Event struct {
+	Region string
+	Result check.Result
+}
+
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+var registry = plugin.NewRegistry[Notifier]("notifiers")
+
+func Register(name string, n Notifier) {
+	registry.Register(name, n)
+}
+
+// Dispatch sends event to every registered notifier. Notifier errors are
+// logged rather than returned so one broken integration cannot fail a check.
+func Dispatch(ctx context.Context, event Event) {
+	for _, name := range registry.Names() {
+		n, _ := registry.Lookup(name)
+		if err := n.Notify(ctx, event); err != nil {
+			logging.From(ctx).Error().Err(err).Str("notifier", name).Msg("Error sending notification")
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package outcome is the shared vocabulary for check results: the status
+// of every result and, for results that are not "up", the reason why.
+// The orchestrator, dashboard backend and alerting services import it (or
+// read GET /v1/outcomes) instead of keeping their own copies of the codes.
+//
+// Codes are only ever added. Consumers should treat an unknown code like
+// the nearest status they do know rather than fail.
+package outcome
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Statuses, from best to worst.
+const (
+	Up        = "up"
+	Throttled = "throttled"
+	Degraded  = "degraded"
+	Down      = "down"
+)
+
+// Failure reasons.
+const (
+	ReasonSlow              = "slow"
+	ReasonThrottled         = "throttled"
+	ReasonHTTPStatus        = "http_status"
+	ReasonHeaderAssertion   = "header_assertion"
+	ReasonDNS               = "dns"
+	ReasonTimeout           = "timeout"
+	ReasonTLS               = "tls"
+	ReasonConnectionRefused = "connection_refused"
+	ReasonConnection        = "connection"
+	ReasonBody              = "body"
+	ReasonConnectionReuse   = "connection_not_reused"
+	ReasonProtocol          = "protocol"
+	ReasonScript            = "script"
+	ReasonHeartbeatMissed   = "heartbeat_missed"
+	ReasonSelector          = "selector"
+	ReasonPageError         = "page_error"
+	ReasonBlockedAddress    = "blocked_address"
+)
+
+// ErrBlockedAddress is returned when a check run for a tenant tries to
+// connect to a loopback, private or link-local address.
+var ErrBlockedAddress = errors.New("checks may not connect to loopback, private or link-local addresses")
+
+// Code is one entry of an enum, as listed by the API.
+type Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
+// Statuses lists every status, from best to worst.
+var Statuses = []Code{
+	{Up, "The target responded as expected."},
+	{Throttled, "The target asked the worker to back off (429, or 503 with Retry-After)."},
+	{Degraded, "The target responded as expected, but slowly."},
+	{Down, "The target did not respond as expected."},
+}
+
+// Reasons lists every failure reason.
+var Reasons = []Code{
+	{ReasonSlow, "The response took longer than the degraded threshold."},
+	{ReasonThrottled, "The target rate limited the check."},
+	{ReasonHTTPStatus, "The response status code was not one of the expected codes."},
+	{ReasonHeaderAssertion, "A response header did not meet the target's expectedHeaders."},
+	{ReasonDNS, "The host name could not be resolved."},
+	{ReasonTimeout, "The check timed out before a response arrived."},
+	{ReasonTLS, "The TLS handshake or certificate verification failed."},
+	{ReasonConnectionRefused, "The target refused the connection."},
+	{ReasonConnection, "The connection failed or was closed for another reason."},
+	{ReasonBody, "The response body could not be read in full."},
+	{ReasonConnectionReuse, "A keepalive check's connection was not kept open."},
+	{ReasonProtocol, "A mail server's greeting or reply was not what the protocol requires."},
+	{ReasonScript, "A scripted check failed or reported a failure."},
+	{ReasonHeartbeatMissed, "No heartbeat ping arrived within the monitor's interval."},
+	{ReasonSelector, "A browser check's waitSelector option did not appear on the page."},
+	{ReasonPageError, "The page threw an uncaught JavaScript error in a browser check."},
+	{ReasonBlockedAddress, "The target resolved to an internal address, which tenants' checks may not reach."},
+}
+
+// Rank orders statuses from best (0) to worst. Unknown statuses rank as
+// Down.
+func Rank(status string) int {
+	for i, c := range Statuses {
+		if c.Code == status {
+			return i
+		}
+	}
+	return len(Statuses) - 1
+}
+
+// ReasonForError classifies an error returned while connecting to or
+// talking to a target.
+func ReasonForError(err error) string {
+	var (
+		dnsErr  *net.DNSError
+		certErr *tls.CertificateVerificationError
+		recErr  tls.RecordHeaderError
+	)
+	switch {
+	case errors.Is(err, ErrBlockedAddress):
+		return ReasonBlockedAddress
+	case errors.As(err, &dnsErr):
+		return ReasonDNS
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ReasonTimeout
+	case errors.As(err, &certErr), errors.As(err, &recErr):
+		return ReasonTLS
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ReasonConnectionRefused
+	default:
+		return ReasonConnection
+	}
+}
@@ -0,0 +1,93 @@
+// Package plugin provides the registries behind the worker's extension
+// points. Each extension point (checkers, notifiers, sinks, discovery
+// sources) owns a Registry, and extensions add themselves to it from an
+// init function so that linking a package is enough to enable it.
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+type Registry[T any] struct {
+	kind  string
+	mu    sync.RWMutex
+	items map[string]T
+}
+
+var (
+	registriesMu sync.Mutex
+	registries   = map[string]interface{ Names() []string }{}
+)
+
+// NewRegistry creates a registry for the extension point kind. The kind is
+// used as the key in Capabilities.
+func NewRegistry[T any](kind string) *Registry[T] {
+	r := &Registry[T]{kind: kind, items: map[string]T{}}
+
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	if _, dup := registries[kind]; dup {
+		panic(fmt.Sprintf("plugin: duplicate registry %q", kind))
+	}
+	registries[kind] = r
+	return r
+}
+
+// Register adds v under name. It panics if name is already taken, since
+// that can only happen when two compiled-in extensions collide.
+func (r *Registry[T]) Register(name string, v T) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, dup := r.items[name]; dup {
+		panic(fmt.Sprintf("plugin: %s %q registered twice", r.kind, name))
+	}
+	r.items[name] = v
+}
+
+func (r *Registry[T]) Lookup(name string) (T, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.items[name]
+	return v, ok
+}
+
+// Names returns the registered names in sorted order.
+func (r *Registry[T]) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.items))
+	for name := range r.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// All returns the registered values ordered by name.
+func (r *Registry[T]) All() []T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.items))
+	for name := range r.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]T, len(names))
+	for i, name := range names {
+		values[i] = r.items[name]
+	}
+	return values
+}
+
+// Capabilities lists every registered extension, keyed by extension point.
+func Capabilities() map[string][]string {
+	registriesMu.Lock()
+	defer registriesMu.Unlock()
+	caps := make(map[string][]string, len(registries))
+	for kind, r := range registries {
+		caps[kind] = r.Names()
+	}
+	return caps
+}
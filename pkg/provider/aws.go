@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode/utf16"
+)
+
+// awsHealth reads the current events feed behind the AWS Health Dashboard.
+type awsHealth struct{}
+
+func init() {
+	Register("aws", awsHealth{})
+}
+
+func (awsHealth) Incidents(ctx context.Context) ([]Incident, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://health.aws.amazon.com/public/currentevents", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("aws health dashboard: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var events []struct {
+		Date        string `json:"date"`
+		RegionName  string `json:"region_name"`
+		ServiceName string `json:"service_name"`
+		Summary     string `json:"summary"`
+	}
+	if err := json.Unmarshal(decodeUTF16(data), &events); err != nil {
+		return nil, err
+	}
+
+	incidents := make([]Incident, 0, len(events))
+	for _, e := range events {
+		incident := Incident{
+			Provider: "aws",
+			Name:     e.ServiceName + " (" + e.RegionName + "): " + e.Summary,
+			URL:      "https://health.aws.amazon.com/health/status",
+		}
+		if secs, err := strconv.ParseInt(e.Date, 10, 64); err == nil {
+			incident.StartedAt = time.Unix(secs, 0).UTC()
+		}
+		incidents = append(incidents, incident)
+	}
+	return incidents, nil
+}
+
+// decodeUTF16 converts the feed to UTF-8. AWS serves it as UTF-16 with a
+// byte order mark; anything without a BOM is returned unchanged.
+func decodeUTF16(data []byte) []byte {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		order = binary.BigEndian
+	default:
+		return data
+	}
+
+	data = data[2:]
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units)))
+}
@@ -0,0 +1,82 @@
+// Package provider looks up incidents declared on the public status pages
+// of infrastructure providers, so results recorded during a provider
+// outage can be annotated and optionally excluded from SLA reports.
+package provider
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/plugin"
+)
+
+type Incident struct {
+	Provider  string    `json:"provider"`
+	Name      string    `json:"name"`
+	Impact    string    `json:"impact,omitempty"`
+	URL       string    `json:"url,omitempty"`
+	StartedAt time.Time `json:"startedAt,omitempty"`
+}
+
+// Source returns a provider's currently unresolved incidents.
+type Source interface {
+	Incidents(ctx context.Context) ([]Incident, error)
+}
+
+var registry = plugin.NewRegistry[Source]("providers")
+
+func Register(name string, s Source) {
+	registry.Register(name, s)
+}
+
+func Known(name string) bool {
+	_, ok := registry.Lookup(name)
+	return ok
+}
+
+type cacheEntry struct {
+	incidents []Incident
+	fetchedAt time.Time
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = map[string]cacheEntry{}
+)
+
+// Active returns the unresolved incidents of the named providers. Status
+// pages are fetched at most once per PROVIDER_STATUS_TTL; a provider whose
+// page cannot be fetched contributes no incidents.
+func Active(ctx context.Context, providers []string) []Incident {
+	ttl := config.Duration("PROVIDER_STATUS_TTL", time.Minute)
+
+	var incidents []Incident
+	for _, name := range providers {
+		src, ok := registry.Lookup(name)
+		if !ok {
+			continue
+		}
+
+		cacheMu.Lock()
+		entry, cached := cache[name]
+		cacheMu.Unlock()
+
+		if !cached || time.Since(entry.fetchedAt) > ttl {
+			list, err := src.Incidents(ctx)
+			if err != nil {
+				logging.From(ctx).Warn().Err(err).Str("provider", name).Msg("Unable to fetch provider status")
+				continue
+			}
+			entry = cacheEntry{incidents: list, fetchedAt: time.Now()}
+
+			cacheMu.Lock()
+			cache[name] = entry
+			cacheMu.Unlock()
+		}
+		incidents = append(incidents, entry.incidents...)
+	}
+	return incidents
+}
@@ -0,0 +1,64 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// statuspage reads the unresolved incidents of an Atlassian Statuspage
+// site, which Cloudflare and Vercel both use.
+type statuspage struct {
+	provider string
+	baseURL  string
+}
+
+func init() {
+	Register("cloudflare", statuspage{provider: "cloudflare", baseURL: "https://www.cloudflarestatus.com"})
+	Register("vercel", statuspage{provider: "vercel", baseURL: "https://www.vercel-status.com"})
+}
+
+var client = &http.Client{Timeout: 5 * time.Second}
+
+func (s statuspage) Incidents(ctx context.Context) ([]Incident, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"/api/v2/incidents/unresolved.json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s status page: %s", s.provider, resp.Status)
+	}
+
+	var body struct {
+		Incidents []struct {
+			Name      string    `json:"name"`
+			Impact    string    `json:"impact"`
+			Shortlink string    `json:"shortlink"`
+			StartedAt time.Time `json:"started_at"`
+		} `json:"incidents"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	incidents := make([]Incident, 0, len(body.Incidents))
+	for _, i := range body.Incidents {
+		incidents = append(incidents, Incident{
+			Provider:  s.provider,
+			Name:      i.Name,
+			Impact:    i.Impact,
+			URL:       i.Shortlink,
+			StartedAt: i.StartedAt,
+		})
+	}
+	return incidents, nil
+}
@@ -0,0 +1,113 @@
+// Package ratelimit implements per-caller token buckets.
+//
+// Every caller gets RATE_LIMIT_RPS requests per second with bursts of up
+// to RATE_LIMIT_BURST. RATE_LIMIT_OVERRIDES sets limits for individual
+// callers as comma-separated key=rps:burst entries. Buckets live in process
+// memory, so each worker instance enforces its limits independently.
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/config"
+)
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+
+	// fullAt is when the bucket will have refilled to its burst. From
+	// then on it is no different from a missing bucket and can be dropped.
+	fullAt time.Time
+}
+
+// Limiter keeps buckets in least recently used order. Buckets that have
+// refilled are dropped as they reach the back, and beyond
+// RATE_LIMIT_MAX_BUCKETS the least recently used ones are dropped even if
+// they have not, so callers that come and go, like heartbeat pings for
+// random monitor IDs, cannot grow it without bound.
+type Limiter struct {
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	order   *list.List
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: map[string]*list.Element{}, order: list.New()}
+}
+
+// Allow takes a token from key's bucket. When the bucket is empty it
+// returns false and how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	return l.allowAt(key, time.Now())
+}
+
+func (l *Limiter) allowAt(key string, now time.Time) (bool, time.Duration) {
+	rate, burst := limits(key)
+	if rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var b *bucket
+	if el, ok := l.buckets[key]; ok {
+		b = el.Value.(*bucket)
+		l.order.MoveToFront(el)
+	} else {
+		b = &bucket{key: key, tokens: burst, last: now}
+		l.buckets[key] = l.order.PushFront(b)
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	allowed, wait := true, time.Duration(0)
+	if b.tokens >= 1 {
+		b.tokens--
+	} else {
+		allowed = false
+		wait = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	b.fullAt = now.Add(time.Duration((burst - b.tokens) / rate * float64(time.Second)))
+
+	l.evict(now)
+	return allowed, wait
+}
+
+// evict drops buckets from the back of the order while they are full or
+// there are more than RATE_LIMIT_MAX_BUCKETS.
+func (l *Limiter) evict(now time.Time) {
+	limit := max(config.Int("RATE_LIMIT_MAX_BUCKETS", 100000), 1)
+	for el := l.order.Back(); el != nil; el = l.order.Back() {
+		b := el.Value.(*bucket)
+		if l.order.Len() <= limit && now.Before(b.fullAt) {
+			return
+		}
+		l.order.Remove(el)
+		delete(l.buckets, b.key)
+	}
+}
+
+func limits(key string) (rate, burst float64) {
+	for _, entry := range config.List("RATE_LIMIT_OVERRIDES") {
+		name, spec, found := strings.Cut(entry, "=")
+		if !found || name != key {
+			continue
+		}
+		r, b, _ := strings.Cut(spec, ":")
+		rate, errR := strconv.ParseFloat(r, 64)
+		burst, errB := strconv.ParseFloat(b, 64)
+		if errR == nil && errB == nil {
+			return rate, math.Max(burst, 1)
+		}
+	}
+	return float64(config.Int("RATE_LIMIT_RPS", 10)), math.Max(float64(config.Int("RATE_LIMIT_BURST", 20)), 1)
+}
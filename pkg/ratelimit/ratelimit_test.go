@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	type call struct {
+		at      time.Duration
+		key     string
+		allowed bool
+		wait    time.Duration
+	}
+	tests := []struct {
+		name      string
+		rps       string
+		burst     string
+		overrides string
+		calls     []call
+	}{
+		{
+			name:  "burst then refill",
+			rps:   "1",
+			burst: "2",
+			calls: []call{
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: false, wait: time.Second},
+				{at: 500 * time.Millisecond, key: "a", allowed: false, wait: 500 * time.Millisecond},
+				{at: time.Second, key: "a", allowed: true},
+			},
+		},
+		{
+			name:  "callers have separate buckets",
+			rps:   "1",
+			burst: "1",
+			calls: []call{
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: false, wait: time.Second},
+				{at: 0, key: "b", allowed: true},
+			},
+		},
+		{
+			name:      "override",
+			rps:       "1",
+			burst:     "1",
+			overrides: "a=10:3",
+			calls: []call{
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: false, wait: 100 * time.Millisecond},
+				{at: 0, key: "b", allowed: true},
+				{at: 0, key: "b", allowed: false, wait: time.Second},
+			},
+		},
+		{
+			name:  "zero rate disables limiting",
+			rps:   "0",
+			burst: "1",
+			calls: []call{
+				{at: 0, key: "a", allowed: true},
+				{at: 0, key: "a", allowed: true},
+			},
+		},
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RATE_LIMIT_RPS", tt.rps)
+			t.Setenv("RATE_LIMIT_BURST", tt.burst)
+			t.Setenv("RATE_LIMIT_OVERRIDES", tt.overrides)
+
+			l := New()
+			for i, c := range tt.calls {
+				allowed, wait := l.allowAt(c.key, start.Add(c.at))
+				if allowed != c.allowed || wait != c.wait {
+					t.Errorf("call %d (%s at %v) = %v, %v; want %v, %v", i, c.key, c.at, allowed, wait, c.allowed, c.wait)
+				}
+			}
+		})
+	}
+}
+
+func TestEviction(t *testing.T) {
+	t.Setenv("RATE_LIMIT_RPS", "1")
+	t.Setenv("RATE_LIMIT_BURST", "2")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("refilled buckets are dropped", func(t *testing.T) {
+		l := New()
+		for i := range 5 {
+			l.allowAt(fmt.Sprint(i), start)
+		}
+		if n := l.order.Len(); n != 5 {
+			t.Fatalf("got %d buckets, want 5", n)
+		}
+		l.allowAt("late", start.Add(time.Second))
+		if n := l.order.Len(); n != 1 {
+			t.Errorf("got %d buckets after refill, want 1", n)
+		}
+	})
+
+	t.Run("bucket count is capped", func(t *testing.T) {
+		t.Setenv("RATE_LIMIT_MAX_BUCKETS", "3")
+		l := New()
+		for i := range 10 {
+			l.allowAt(fmt.Sprint(i), start)
+		}
+		if n := l.order.Len(); n != 3 {
+			t.Errorf("got %d buckets, want 3", n)
+		}
+		if _, ok := l.buckets["9"]; !ok {
+			t.Error("most recent bucket was evicted")
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package resource
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/storage"
+)
+
+// Kinds returns the resource kinds backed by db. Websites are only
+// available on Postgres, where the websites table lives.
+func Kinds(db *sql.DB, dialect storage.Dialect) []Kind {
+	docs := func(name string, spec func() validator) Kind {
+		return Kind{Name: name, Normalize: normalizer(spec), Store: &SQL{DB: db, Dialect: dialect, Kind: name}}
+	}
+
+	kinds := []Kind{
+		docs("templates", func() validator { return &TemplateSpec{} }),
+		docs("channels", func() validator { return &ChannelSpec{} }),
+		docs("maintenance-windows", func() validator { return &MaintenanceWindowSpec{} }),
+	}
+	if dialect == storage.Postgres {
+		kinds = append(kinds, Kind{
+			Name:      "websites",
+			Normalize: normalizer(func() validator { return &WebsiteSpec{} }),
+			Store:     &Websites{DB: db},
+		})
+	}
+	return kinds
+}
+
+type validator interface {
+	validate() error
+}
+
+// normalizer returns a Normalize function that decodes specs strictly
+// into a fresh value from newSpec, validates it and re-encodes it so
+// stored specs have a canonical form.
+func normalizer(newSpec func() validator) func(json.RawMessage) (json.RawMessage, error) {
+	return func(spec json.RawMessage) (json.RawMessage, error) {
+		v := newSpec()
+		if err := decodeStrict(spec, v); err != nil {
+			return nil, err
+		}
+		if err := v.validate(); err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	}
+}
+
+func (w *WebsiteSpec) validate() error {
+	if w.IntervalSeconds <= 0 {
+		return errors.New("intervalSeconds must be positive")
+	}
+	if w.Confirmations < 0 {
+		return errors.New("confirmations must not be negative")
+	}
+	if w.Tags == nil {
+		w.Tags = []string{}
+	}
+	target := check.Target{
+		WebsiteID:       uuid.New(),
+		URL:             w.URL,
+		CheckType:       w.CheckType,
+		Script:          w.Script,
+		IntervalSeconds: w.IntervalSeconds,
+	}
+	return check.Validate(target)
+}
+
+// TemplateSpec is a named set of defaults for websites.
+type TemplateSpec struct {
+	Name            string `json:"name"`
+	CheckType       string `json:"checkType,omitempty"`
+	IntervalSeconds int    `json:"intervalSeconds,omitempty"`
+	Confirmations   int    `json:"confirmations,omitempty"`
+}
+
+func (t *TemplateSpec) validate() error {
+	if t.Name == "" {
+		return errors.New("name is required")
+	}
+	if t.IntervalSeconds < 0 || t.Confirmations < 0 {
+		return errors.New("intervalSeconds and confirmations must not be negative")
+	}
+	return nil
+}
+
+// ChannelSpec is a notification channel. Config is passed to the
+// notifier of the given type as-is.
+type ChannelSpec struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+func (c *ChannelSpec) validate() error {
+	if c.Name == "" || c.Type == "" {
+		return errors.New("name and type are required")
+	}
+	return nil
+}
+
+// MaintenanceWindowSpec describes planned maintenance for the listed
+// websites, or all of them when none are listed, between Start and End.
+// Windows are only stored for API clients to read back; notifiers and
+// incident tracking do not consult them.
+type MaintenanceWindowSpec struct {
+	Name       string      `json:"name"`
+	WebsiteIDs []uuid.UUID `json:"websiteIds,omitempty"`
+	Start      time.Time   `json:"start"`
+	End        time.Time   `json:"end"`
+}
+
+func (m *MaintenanceWindowSpec) validate() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if !m.Start.Before(m.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Package resource provides CRUD over the worker's configuration objects
+// with the guarantees an infrastructure-as-code client needs: IDs never
+// change, every write bumps a version that is exposed as an ETag and
+// checked against If-Match, and creates retried with the same
+// Idempotency-Key return the original object instead of a duplicate.
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound        = errors.New("resource not found")
+	ErrVersionMismatch = errors.New("resource version does not match")
+	ErrConflict        = errors.New("resource already exists with a different spec")
+)
+
+type Resource struct {
+	ID      uuid.UUID       `json:"id"`
+	Version int             `json:"version"`
+	Spec    json.RawMessage `json:"spec"`
+}
+
+// Store persists one kind of resource. Update and Delete succeed only
+// when version matches the stored version.
+type Store interface {
+	List(ctx context.Context) ([]Resource, error)
+	Get(ctx context.Context, id uuid.UUID) (Resource, error)
+
+	// Create stores spec under id. If id already exists with an equal
+	// spec the existing resource is returned with created false; with a
+	// different spec it fails with ErrConflict.
+	Create(ctx context.Context, id uuid.UUID, spec json.RawMessage) (r Resource, created bool, err error)
+	Update(ctx context.Context, id uuid.UUID, version int, spec json.RawMessage) (Resource, error)
+	Delete(ctx context.Context, id uuid.UUID, version int) error
+}
+
+// Kind is a resource type exposed under /v1/<Name>.
+type Kind struct {
+	Name string
+
+	// Normalize validates a spec and returns it in canonical form.
+	Normalize func(spec json.RawMessage) (json.RawMessage, error)
+
+	Store Store
+}
+
+// idNamespace scopes IDs derived from idempotency keys.
+var idNamespace = uuid.MustParse("5b0f7c52-3f1e-4a8e-9d8e-6c1f4a2b9e01")
+
+// IDForKey derives the stable ID of a resource created with the given
+// Idempotency-Key, so retries of the same create resolve to the same ID.
+func IDForKey(kind, key string) uuid.UUID {
+	return uuid.NewSHA1(idNamespace, []byte(kind+"\n"+key))
+}
+
+// sameSpec reports whether a and b encode the same JSON value, ignoring
+// formatting and key order.
+func sameSpec(a, b json.RawMessage) bool {
+	var va, vb any
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,124 @@
+package resource
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/storage"
+)
+
+// SQL stores resources of one kind as JSON documents in the resources
+// table.
+type SQL struct {
+	DB      *sql.DB
+	Dialect storage.Dialect
+	Kind    string
+}
+
+func (s *SQL) List(ctx context.Context) ([]Resource, error) {
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.Rebind(
+		`SELECT id, version, spec FROM resources WHERE kind = $1 ORDER BY id`), s.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []Resource{}
+	for rows.Next() {
+		r, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	return resources, rows.Err()
+}
+
+func (s *SQL) Get(ctx context.Context, id uuid.UUID) (Resource, error) {
+	r, err := scan(s.DB.QueryRowContext(ctx, s.Dialect.Rebind(
+		`SELECT id, version, spec FROM resources WHERE kind = $1 AND id = $2`), s.Kind, id.String()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+func (s *SQL) Create(ctx context.Context, id uuid.UUID, spec json.RawMessage) (Resource, bool, error) {
+	insert := `INSERT INTO resources (kind, id, spec, version, updated_at) VALUES ($1, $2, $3, 1, $4)
+		ON CONFLICT (kind, id) DO NOTHING`
+	if s.Dialect == storage.MySQL {
+		insert = `INSERT IGNORE INTO resources (kind, id, spec, version, updated_at) VALUES ($1, $2, $3, 1, $4)`
+	}
+	res, err := s.DB.ExecContext(ctx, s.Dialect.Rebind(insert),
+		s.Kind, id.String(), string(spec), s.Dialect.Time(time.Now()))
+	if err != nil {
+		return Resource{}, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return Resource{}, false, err
+	} else if n == 1 {
+		return Resource{ID: id, Version: 1, Spec: spec}, true, nil
+	}
+
+	existing, err := s.Get(ctx, id)
+	if err != nil {
+		return Resource{}, false, err
+	}
+	if !sameSpec(existing.Spec, spec) {
+		return existing, false, ErrConflict
+	}
+	return existing, false, nil
+}
+
+func (s *SQL) Update(ctx context.Context, id uuid.UUID, version int, spec json.RawMessage) (Resource, error) {
+	res, err := s.DB.ExecContext(ctx, s.Dialect.Rebind(
+		`UPDATE resources SET spec = $1, version = version + 1, updated_at = $2
+		WHERE kind = $3 AND id = $4 AND version = $5`),
+		string(spec), s.Dialect.Time(time.Now()), s.Kind, id.String(), version)
+	if err := s.checkWrite(ctx, id, res, err); err != nil {
+		return Resource{}, err
+	}
+	return Resource{ID: id, Version: version + 1, Spec: spec}, nil
+}
+
+func (s *SQL) Delete(ctx context.Context, id uuid.UUID, version int) error {
+	res, err := s.DB.ExecContext(ctx, s.Dialect.Rebind(
+		`DELETE FROM resources WHERE kind = $1 AND id = $2 AND version = $3`), s.Kind, id.String(), version)
+	return s.checkWrite(ctx, id, res, err)
+}
+
+// checkWrite turns a versioned write that matched no row into
+// ErrNotFound or ErrVersionMismatch.
+func (s *SQL) checkWrite(ctx context.Context, id uuid.UUID, res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner) (Resource, error) {
+	var (
+		r    Resource
+		spec string
+	)
+	if err := row.Scan(&r.ID, &r.Version, &spec); err != nil {
+		return r, err
+	}
+	r.Spec = json.RawMessage(spec)
+	return r, nil
+}
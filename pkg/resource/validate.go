@@ -0,0 +1,169 @@
+package resource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/provider"
+	"monitor-workder/pkg/worker"
+)
+
+// Config is a complete declarative monitoring configuration, as kept in
+// version control by GitOps users.
+type Config struct {
+	Websites           []json.RawMessage `json:"websites"`
+	Templates          []json.RawMessage `json:"templates"`
+	Channels           []json.RawMessage `json:"channels"`
+	MaintenanceWindows []json.RawMessage `json:"maintenanceWindows"`
+}
+
+// configWebsite is a website in a Config: its stored spec plus the
+// per-check assertions sent with each check request.
+type configWebsite struct {
+	ID *uuid.UUID `json:"id,omitempty"`
+	WebsiteSpec
+	ExpectedStatusCodes check.StatusCodes      `json:"expectedStatusCodes,omitempty"`
+	ExpectedHeaders     check.HeaderAssertions `json:"expectedHeaders,omitempty"`
+	Providers           []string               `json:"providers,omitempty"`
+}
+
+// ValidateConfig checks every object in a declarative config and the
+// references between them, returning all problems found. It never
+// touches storage.
+func ValidateConfig(data []byte) []worker.Problem {
+	problems := []worker.Problem{}
+	add := func(field, format string, args ...any) {
+		problems = append(problems, worker.Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var cfg Config
+	if err := decodeStrict(data, &cfg); err != nil {
+		add("", "config is not valid: %s", err)
+		return problems
+	}
+
+	templates := map[string]bool{}
+	for i, raw := range cfg.Templates {
+		field := fmt.Sprintf("templates[%d]", i)
+		var t TemplateSpec
+		if err := decodeStrict(raw, &t); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+		if err := t.validate(); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+		if templates[t.Name] {
+			add(field+".name", "duplicates template %q", t.Name)
+		}
+		templates[t.Name] = true
+	}
+
+	channels := map[string]bool{}
+	for i, raw := range cfg.Channels {
+		field := fmt.Sprintf("channels[%d]", i)
+		var c ChannelSpec
+		if err := decodeStrict(raw, &c); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+		if err := c.validate(); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+		if channels[c.Name] {
+			add(field+".name", "duplicates channel %q", c.Name)
+		}
+		channels[c.Name] = true
+	}
+
+	websites := map[uuid.UUID]bool{}
+	for i, raw := range cfg.Websites {
+		field := fmt.Sprintf("websites[%d]", i)
+		var w configWebsite
+		if err := decodeStrict(raw, &w); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+
+		if w.ID != nil {
+			if websites[*w.ID] {
+				add(field+".id", "duplicates website %s", w.ID)
+			}
+			websites[*w.ID] = true
+		}
+		if w.IntervalSeconds <= 0 {
+			add(field+".intervalSeconds", "must be positive")
+		}
+		if w.Confirmations < 0 || w.Confirmations > worker.MaxConfirmations() {
+			add(field+".confirmations", "must be between 0 and %d", worker.MaxConfirmations())
+		}
+		if w.Template != "" && !templates[w.Template] {
+			add(field+".template", "refers to unknown template %q", w.Template)
+		}
+		for j, name := range w.Providers {
+			if !provider.Known(name) {
+				add(fmt.Sprintf("%s.providers[%d]", field, j), "is not a supported provider")
+			}
+		}
+
+		target := check.Target{
+			WebsiteID:           uuid.New(),
+			URL:                 w.URL,
+			CheckType:           w.CheckType,
+			Script:              w.Script,
+			IntervalSeconds:     w.IntervalSeconds,
+			ExpectedStatusCodes: w.ExpectedStatusCodes,
+			ExpectedHeaders:     w.ExpectedHeaders,
+		}
+		if err := check.Validate(target); err != nil {
+			var tv *check.TargetError
+			if errors.As(err, &tv) {
+				add(field+"."+tv.Field, "%s", tv.Message)
+			} else {
+				add(field, "%s", err)
+			}
+		}
+	}
+
+	for i, raw := range cfg.MaintenanceWindows {
+		field := fmt.Sprintf("maintenanceWindows[%d]", i)
+		var m MaintenanceWindowSpec
+		if err := decodeStrict(raw, &m); err != nil {
+			add(field, "%s", err)
+			continue
+		}
+		if err := m.validate(); err != nil {
+			add(field, "%s", err)
+		}
+		for j, id := range m.WebsiteIDs {
+			if !websites[id] {
+				add(fmt.Sprintf("%s.websiteIds[%d]", field, j), "refers to unknown website %s", id)
+			}
+		}
+	}
+
+	slices.SortStableFunc(problems, func(a, b worker.Problem) int {
+		if a.Field < b.Field {
+			return -1
+		}
+		if a.Field > b.Field {
+			return 1
+		}
+		return 0
+	})
+	return problems
+}
+
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
@@ -0,0 +1,146 @@
+package resource
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WebsiteSpec is the configurable part of a row in the websites table.
+type WebsiteSpec struct {
+	URL             string   `json:"url"`
+	CheckType       string   `json:"checkType,omitempty"`
+	Script          string   `json:"script,omitempty"`
+	IntervalSeconds int      `json:"intervalSeconds"`
+	Tags            []string `json:"tags"`
+	Template        string   `json:"template,omitempty"`
+	Paused          bool     `json:"paused"`
+	Confirmations   int      `json:"confirmations"`
+}
+
+// Websites stores websites in their own table rather than as documents,
+// since the scheduler reads them from there. It requires Postgres.
+type Websites struct {
+	DB *sql.DB
+}
+
+const websiteColumns = `id, version, url, coalesce(check_type, ''), coalesce(script, ''), check_interval,
+	tags, coalesce(template, ''), paused, confirmations`
+
+func (s *Websites) List(ctx context.Context) ([]Resource, error) {
+	rows, err := s.DB.QueryContext(ctx, `SELECT `+websiteColumns+` FROM websites ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	resources := []Resource{}
+	for rows.Next() {
+		r, err := scanWebsite(rows)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	return resources, rows.Err()
+}
+
+func (s *Websites) Get(ctx context.Context, id uuid.UUID) (Resource, error) {
+	r, err := scanWebsite(s.DB.QueryRowContext(ctx, `SELECT `+websiteColumns+` FROM websites WHERE id = $1`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+func (s *Websites) Create(ctx context.Context, id uuid.UUID, spec json.RawMessage) (Resource, bool, error) {
+	var w WebsiteSpec
+	if err := json.Unmarshal(spec, &w); err != nil {
+		return Resource{}, false, err
+	}
+
+	res, err := s.DB.ExecContext(ctx,
+		`INSERT INTO websites (id, version, url, check_type, script, check_interval, tags, template, paused, confirmations)
+		VALUES ($1, 1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (id) DO NOTHING`,
+		id, w.URL, nullString(w.CheckType), nullString(w.Script), w.IntervalSeconds,
+		pq.Array(w.Tags), nullString(w.Template), w.Paused, w.Confirmations)
+	if err != nil {
+		return Resource{}, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return Resource{}, false, err
+	} else if n == 1 {
+		return Resource{ID: id, Version: 1, Spec: spec}, true, nil
+	}
+
+	existing, err := s.Get(ctx, id)
+	if err != nil {
+		return Resource{}, false, err
+	}
+	if !sameSpec(existing.Spec, spec) {
+		return existing, false, ErrConflict
+	}
+	return existing, false, nil
+}
+
+func (s *Websites) Update(ctx context.Context, id uuid.UUID, version int, spec json.RawMessage) (Resource, error) {
+	var w WebsiteSpec
+	if err := json.Unmarshal(spec, &w); err != nil {
+		return Resource{}, err
+	}
+
+	res, err := s.DB.ExecContext(ctx,
+		`UPDATE websites SET version = version + 1, url = $1, check_type = $2, script = $3,
+			check_interval = $4, tags = $5, template = $6, paused = $7, confirmations = $8
+		WHERE id = $9 AND version = $10`,
+		w.URL, nullString(w.CheckType), nullString(w.Script), w.IntervalSeconds,
+		pq.Array(w.Tags), nullString(w.Template), w.Paused, w.Confirmations, id, version)
+	if err := s.checkWrite(ctx, id, res, err); err != nil {
+		return Resource{}, err
+	}
+	return Resource{ID: id, Version: version + 1, Spec: spec}, nil
+}
+
+func (s *Websites) Delete(ctx context.Context, id uuid.UUID, version int) error {
+	res, err := s.DB.ExecContext(ctx, `DELETE FROM websites WHERE id = $1 AND version = $2`, id, version)
+	return s.checkWrite(ctx, id, res, err)
+}
+
+func (s *Websites) checkWrite(ctx context.Context, id uuid.UUID, res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return err
+	}
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return ErrVersionMismatch
+}
+
+func scanWebsite(row scanner) (Resource, error) {
+	var (
+		r Resource
+		w WebsiteSpec
+	)
+	if err := row.Scan(&r.ID, &r.Version, &w.URL, &w.CheckType, &w.Script, &w.IntervalSeconds,
+		pq.Array(&w.Tags), &w.Template, &w.Paused, &w.Confirmations); err != nil {
+		return r, err
+	}
+	if w.Tags == nil {
+		w.Tags = []string{}
+	}
+	spec, err := json.Marshal(w)
+	r.Spec = spec
+	return r, err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
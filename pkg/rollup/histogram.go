@@ -0,0 +1,54 @@
+package rollup
+
+import (
+	"maps"
+	"math"
+	"slices"
+)
+
+// histogram counts latencies by their value in milliseconds rounded up to
+// two significant digits. Histograms of the same bucket add up, so a
+// percentile read from the sum is within 10% of the exact value however
+// the bucket's results were split across batches and runs.
+type histogram map[int64]int
+
+// histogramKey rounds ms up to two significant digits.
+func histogramKey(ms int64) int64 {
+	scale := int64(1)
+	for ms/scale >= 100 {
+		scale *= 10
+	}
+	return (ms + scale - 1) / scale * scale
+}
+
+func (h histogram) add(ms int64) {
+	h[histogramKey(ms)]++
+}
+
+func (h histogram) merge(other histogram) {
+	for k, n := range other {
+		h[k] += n
+	}
+}
+
+// percentile returns the nearest-rank pth percentile, or false if h is
+// empty.
+func (h histogram) percentile(p float64) (int64, bool) {
+	total := 0
+	for _, n := range h {
+		total += n
+	}
+	if total == 0 {
+		return 0, false
+	}
+	rank := int(math.Ceil(p / 100 * float64(total)))
+	seen := 0
+	keys := slices.Sorted(maps.Keys(h))
+	for _, k := range keys {
+		seen += h[k]
+		if seen >= rank {
+			return k, true
+		}
+	}
+	return keys[len(keys)-1], true
+}
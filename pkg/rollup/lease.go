@@ -0,0 +1,51 @@
+package rollup
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/storage"
+)
+
+// ErrRunning is returned by Run while another run holds the lease.
+var ErrRunning = errors.New("rollup already running")
+
+const leaseName = "rollup"
+
+// acquire takes the rollup lease in job_leases, replacing one that has
+// lapsed, and returns a function that gives it up. The lease lasts
+// ROLLUP_LEASE, which should exceed the longest run, so a run that crashes
+// blocks others only until then.
+func (j *Job) acquire(ctx context.Context) (release func(), err error) {
+	now := time.Now()
+	if _, err := j.DB.ExecContext(ctx, j.Dialect.Rebind(
+		`DELETE FROM job_leases WHERE name = $1 AND expires_at < $2`),
+		leaseName, j.Dialect.Time(now)); err != nil {
+		return nil, err
+	}
+
+	holder := uuid.NewString()
+	insert := `INSERT INTO job_leases (name, holder, expires_at) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING`
+	if j.Dialect == storage.MySQL {
+		insert = `INSERT IGNORE INTO job_leases (name, holder, expires_at) VALUES ($1, $2, $3)`
+	}
+	res, err := j.DB.ExecContext(ctx, j.Dialect.Rebind(insert),
+		leaseName, holder, j.Dialect.Time(now.Add(config.Duration("ROLLUP_LEASE", 15*time.Minute))))
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n != 1 {
+		return nil, ErrRunning
+	}
+
+	return func() {
+		j.DB.ExecContext(context.WithoutCancel(ctx), j.Dialect.Rebind(
+			`DELETE FROM job_leases WHERE name = $1 AND holder = $2`), leaseName, holder)
+	}, nil
+}
@@ -0,0 +1,316 @@
+// Package rollup enforces raw result retention. Raw uptime_checks rows
+// older than RETENTION_DAYS are aggregated into hourly and daily buckets
+// per website and region in uptime_rollups, then deleted.
+//
+// Raw rows are processed in batches, each in its own transaction, and a
+// bucket may be touched by several batches or by results that arrive late.
+// Buckets are therefore merged incrementally: counts, sums and latency
+// histograms are added to the stored row and the derived columns
+// recomputed, so results replayed or spooled long after their hour land in
+// the right buckets without rebuilding them. The p95 of a merged bucket
+// comes from its histogram and is within 10% of the exact value; buckets
+// stored before histograms were kept use the larger of the two p95s.
+//
+// Daily buckets start at midnight in the timezone of the website's tenant,
+// from tenant_settings, or in ROLLUP_TIMEZONE (default UTC) for websites
+// without one. Days already rolled up keep their boundaries if a tenant
+// later changes timezone.
+// Only one job runs at a time: Run holds a lease in job_leases and fails
+// with ErrRunning while another instance holds it.
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/storage"
+)
+
+type Job struct {
+	DB      *sql.DB
+	Dialect storage.Dialect
+}
+
+// Report summarises one Run.
+type Report struct {
+	Cutoff    time.Time `json:"cutoff"`
+	RowsMoved int       `json:"rowsMoved"`
+	Buckets   int       `json:"buckets"`
+
+	// Remaining is true when Run stopped at ROLLUP_MAX_BATCHES with rows
+	// still due, so the caller should run it again.
+	Remaining bool `json:"remaining"`
+}
+
+// Cutoff is the time before which raw rows are rolled up.
+func Cutoff() time.Time {
+	return time.Now().Add(-time.Duration(config.Int("RETENTION_DAYS", 30)) * 24 * time.Hour)
+}
+
+// Run rolls up raw rows checked before cutoff, processing at most
+// ROLLUP_MAX_BATCHES batches of ROLLUP_BATCH_SIZE rows so it fits within a
+// serverless invocation. It returns ErrRunning if another run is in
+// progress.
+func (j *Job) Run(ctx context.Context, cutoff time.Time) (Report, error) {
+	report := Report{Cutoff: cutoff.UTC()}
+
+	loc, err := time.LoadLocation(config.String("ROLLUP_TIMEZONE", "UTC"))
+	if err != nil {
+		return report, fmt.Errorf("invalid ROLLUP_TIMEZONE: %w", err)
+	}
+	tenantZones := &zones{fallback: loc, byName: map[string]*time.Location{}}
+
+	release, err := j.acquire(ctx)
+	if err != nil {
+		return report, err
+	}
+	defer release()
+
+	batchSize := config.Int("ROLLUP_BATCH_SIZE", 5000)
+	for range config.Int("ROLLUP_MAX_BATCHES", 20) {
+		rows, buckets, err := j.batch(ctx, cutoff, batchSize, tenantZones)
+		report.RowsMoved += rows
+		report.Buckets += buckets
+		if err != nil {
+			return report, err
+		}
+		if rows < batchSize {
+			return report, nil
+		}
+	}
+	report.Remaining = true
+	return report, nil
+}
+
+type key struct {
+	websiteID   string
+	region      string
+	granularity string
+	start       time.Time
+}
+
+type bucket struct {
+	checks, up, down, throttled int
+	latencies                   []int64
+}
+
+// zones resolves tenant timezones for one Run. Websites without a tenant
+// timezone, or with one that does not load, use fallback.
+type zones struct {
+	fallback *time.Location
+	byName   map[string]*time.Location
+}
+
+func (z *zones) lookup(ctx context.Context, name string) *time.Location {
+	if name == "" {
+		return z.fallback
+	}
+	if loc, ok := z.byName[name]; ok {
+		return loc
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		logging.From(ctx).Warn().Err(err).Str("timezone", name).Msg("Invalid tenant timezone, using ROLLUP_TIMEZONE")
+		loc = z.fallback
+	}
+	z.byName[name] = loc
+	return loc
+}
+
+// batch rolls up and deletes the oldest batchSize due rows.
+func (j *Job) batch(ctx context.Context, cutoff time.Time, batchSize int, zones *zones) (rows, buckets int, err error) {
+	tx, err := j.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	checkedAt := "coalesce(c.checked_at, c.created_at)"
+	result, err := tx.QueryContext(ctx, j.Dialect.Rebind(
+		`SELECT c.id, c.website_id, coalesce(c.region, ''), c.status, c.response_time, `+j.Dialect.TimeColumn(checkedAt)+`,
+			coalesce(s.timezone, '')
+		FROM uptime_checks c
+		LEFT JOIN websites w ON w.id = c.website_id
+		LEFT JOIN tenant_settings s ON s.tenant_id = w.tenant_id
+		WHERE `+checkedAt+` < $1
+		ORDER BY `+checkedAt+` LIMIT `+fmt.Sprint(batchSize)),
+		j.Dialect.Time(cutoff))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var (
+		ids     []int64
+		pending = map[key]*bucket{}
+	)
+	for result.Next() {
+		var (
+			id                        int64
+			websiteID, region, status string
+			responseTime              int64
+			raw, timezone             string
+		)
+		if err := result.Scan(&id, &websiteID, &region, &status, &responseTime, &raw, &timezone); err != nil {
+			result.Close()
+			return 0, 0, err
+		}
+		at, err := storage.ParseTime(raw)
+		if err != nil {
+			result.Close()
+			return 0, 0, err
+		}
+		ids = append(ids, id)
+
+		loc := zones.lookup(ctx, timezone)
+		local := at.In(loc)
+		for _, k := range []key{
+			{websiteID, region, "hour", at.UTC().Truncate(time.Hour)},
+			{websiteID, region, "day", time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).UTC()},
+		} {
+			b := pending[k]
+			if b == nil {
+				b = &bucket{}
+				pending[k] = b
+			}
+			b.add(status, responseTime)
+		}
+	}
+	result.Close()
+	if err := result.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	for k, b := range pending {
+		if err := j.merge(ctx, tx, k, b); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	for chunk := range slices.Chunk(ids, 500) {
+		placeholders := make([]string, len(chunk))
+		args := make([]any, len(chunk))
+		for i, id := range chunk {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = id
+		}
+		if _, err := tx.ExecContext(ctx, j.Dialect.Rebind(
+			`DELETE FROM uptime_checks WHERE id IN (`+strings.Join(placeholders, ", ")+`)`), args...); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(ids), len(pending), tx.Commit()
+}
+
+func (b *bucket) add(status string, responseTime int64) {
+	b.checks++
+	switch status {
+	case "up", "degraded":
+		b.up++
+		b.latencies = append(b.latencies, responseTime)
+	case "throttled":
+		b.throttled++
+	default:
+		b.down++
+	}
+}
+
+// merge adds b to the stored bucket for k, creating it if needed.
+func (j *Job) merge(ctx context.Context, tx *sql.Tx, k key, b *bucket) error {
+	var latencySum int64
+	hist := histogram{}
+	for _, l := range b.latencies {
+		latencySum += l
+		hist.add(l)
+	}
+	p95 := sql.NullInt64{}
+	if len(b.latencies) > 0 {
+		slices.Sort(b.latencies)
+		rank := int(math.Ceil(0.95 * float64(len(b.latencies))))
+		p95 = sql.NullInt64{Int64: b.latencies[rank-1], Valid: true}
+	}
+
+	var (
+		checks, up, down, throttled, latencyCount = b.checks, b.up, b.down, b.throttled, len(b.latencies)
+		storedP95                                 sql.NullInt64
+		storedSum                                 int64
+		storedHist                                sql.NullString
+	)
+	where := `WHERE website_id = $1 AND region = $2 AND granularity = $3 AND bucket_start = $4`
+	args := []any{k.websiteID, k.region, k.granularity, j.Dialect.Time(k.start)}
+
+	var c, u, d, t, lc int
+	err := tx.QueryRowContext(ctx, j.Dialect.Rebind(
+		`SELECT checks, up_checks, down_checks, throttled_checks, latency_count, latency_sum, p95_response_time,
+			latency_histogram
+		FROM uptime_rollups `+where), args...).Scan(&c, &u, &d, &t, &lc, &storedSum, &storedP95, &storedHist)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if exists {
+		checks, up, down, throttled, latencyCount = checks+c, up+u, down+d, throttled+t, latencyCount+lc
+		latencySum += storedSum
+		switch {
+		case storedHist.Valid:
+			stored := histogram{}
+			if err := json.Unmarshal([]byte(storedHist.String), &stored); err != nil {
+				return fmt.Errorf("decoding latency histogram: %w", err)
+			}
+			hist.merge(stored)
+			v, ok := hist.percentile(95)
+			p95 = sql.NullInt64{Int64: v, Valid: ok}
+		case lc > 0:
+			// The stored latencies were not kept, so the merged
+			// histogram would miss them.
+			hist = nil
+			if storedP95.Valid && (!p95.Valid || storedP95.Int64 > p95.Int64) {
+				p95 = storedP95
+			}
+		}
+	}
+
+	histJSON := sql.NullString{}
+	if hist != nil {
+		raw, err := json.Marshal(hist)
+		if err != nil {
+			return err
+		}
+		histJSON = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	uptime, avg := sql.NullFloat64{}, sql.NullFloat64{}
+	if counted := checks - throttled; counted > 0 {
+		uptime = sql.NullFloat64{Float64: float64(up) * 100 / float64(counted), Valid: true}
+	}
+	if latencyCount > 0 {
+		avg = sql.NullFloat64{Float64: float64(latencySum) / float64(latencyCount), Valid: true}
+	}
+
+	values := []any{checks, up, down, throttled, latencyCount, latencySum, uptime, avg, p95, histJSON}
+	if exists {
+		_, err = tx.ExecContext(ctx, j.Dialect.Rebind(
+			`UPDATE uptime_rollups SET checks = $1, up_checks = $2, down_checks = $3, throttled_checks = $4,
+				latency_count = $5, latency_sum = $6, uptime_pct = $7, avg_response_time = $8, p95_response_time = $9,
+				latency_histogram = $10
+			WHERE website_id = $11 AND region = $12 AND granularity = $13 AND bucket_start = $14`),
+			append(values, args...)...)
+		return err
+	}
+	_, err = tx.ExecContext(ctx, j.Dialect.Rebind(
+		`INSERT INTO uptime_rollups (website_id, region, granularity, bucket_start,
+			checks, up_checks, down_checks, throttled_checks, latency_count, latency_sum,
+			uptime_pct, avg_response_time, p95_response_time, latency_histogram)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`),
+		append(args, values...)...)
+	return err
+}
@@ -0,0 +1,282 @@
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"monitor-workder/pkg/storage"
+)
+
+func openTestJob(t *testing.T) *Job {
+	t.Helper()
+	conn, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+
+	for _, name := range []string{"0001_schema.sql", "0004_clock_skew.sql", "0010_rollups.sql", "0012_api_keys.sql",
+		"0017_job_leases.sql", "0020_tenant_settings.sql", "0021_rollup_histograms.sql"} {
+		migration, err := os.ReadFile("../../migrations/sqlite/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.Exec(string(migration)); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	return &Job{DB: conn, Dialect: storage.SQLite}
+}
+
+type rawResult struct {
+	at           string
+	status       string
+	responseTime int64
+}
+
+type rollupRow struct {
+	granularity string
+	start       string
+	checks      int
+	up          int
+	down        int
+	throttled   int
+	p95         sql.NullInt64
+}
+
+func TestRunBuckets(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		tenantTZ string
+		results  []rawResult
+		want     []rollupRow
+	}{
+		{
+			name:     "one hour",
+			timezone: "UTC",
+			results: []rawResult{
+				{"2024-03-01T10:05:00.000Z", "up", 100},
+				{"2024-03-01T10:55:00.000Z", "degraded", 300},
+				{"2024-03-01T10:30:00.000Z", "down", 0},
+				{"2024-03-01T10:40:00.000Z", "throttled", 0},
+			},
+			want: []rollupRow{
+				{"day", "2024-03-01T00:00:00.000Z", 4, 2, 1, 1, sql.NullInt64{Int64: 300, Valid: true}},
+				{"hour", "2024-03-01T10:00:00.000Z", 4, 2, 1, 1, sql.NullInt64{Int64: 300, Valid: true}},
+			},
+		},
+		{
+			name:     "hours split, day shared",
+			timezone: "UTC",
+			results: []rawResult{
+				{"2024-03-01T10:59:59.000Z", "up", 100},
+				{"2024-03-01T11:00:00.000Z", "down", 0},
+			},
+			want: []rollupRow{
+				{"day", "2024-03-01T00:00:00.000Z", 2, 1, 1, 0, sql.NullInt64{Int64: 100, Valid: true}},
+				{"hour", "2024-03-01T10:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 100, Valid: true}},
+				{"hour", "2024-03-01T11:00:00.000Z", 1, 0, 1, 0, sql.NullInt64{}},
+			},
+		},
+		{
+			name:     "days start at local midnight",
+			timezone: "America/New_York",
+			results: []rawResult{
+				{"2024-03-01T04:30:00.000Z", "up", 50},
+				{"2024-03-01T05:30:00.000Z", "up", 70},
+			},
+			want: []rollupRow{
+				{"day", "2024-02-29T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+				{"day", "2024-03-01T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 70, Valid: true}},
+				{"hour", "2024-03-01T04:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+				{"hour", "2024-03-01T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 70, Valid: true}},
+			},
+		},
+		{
+			name:     "days start at the tenant's midnight",
+			timezone: "UTC",
+			tenantTZ: "America/New_York",
+			results: []rawResult{
+				{"2024-03-01T04:30:00.000Z", "up", 50},
+				{"2024-03-01T05:30:00.000Z", "up", 70},
+			},
+			want: []rollupRow{
+				{"day", "2024-02-29T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+				{"day", "2024-03-01T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 70, Valid: true}},
+				{"hour", "2024-03-01T04:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+				{"hour", "2024-03-01T05:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 70, Valid: true}},
+			},
+		},
+		{
+			name:     "invalid tenant timezone falls back",
+			timezone: "UTC",
+			tenantTZ: "Mars/Olympus_Mons",
+			results: []rawResult{
+				{"2024-03-01T04:30:00.000Z", "up", 50},
+			},
+			want: []rollupRow{
+				{"day", "2024-03-01T00:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+				{"hour", "2024-03-01T04:00:00.000Z", 1, 1, 0, 0, sql.NullInt64{Int64: 50, Valid: true}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := openTestJob(t)
+			t.Setenv("ROLLUP_TIMEZONE", tt.timezone)
+			if tt.tenantTZ != "" {
+				if _, err := job.DB.Exec(`INSERT INTO websites (id, tenant_id) VALUES ('w1', 't1')`); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := job.DB.Exec(`INSERT INTO tenant_settings (tenant_id, timezone) VALUES ('t1', ?)`, tt.tenantTZ); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, r := range tt.results {
+				if _, err := job.DB.Exec(`INSERT INTO uptime_checks (website_id, region, status, response_time, status_code, checked_at)
+					VALUES ('w1', 'eu', ?, ?, 200, ?)`, r.status, r.responseTime, r.at); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := job.Run(context.Background(), time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("Run() = %v", err)
+			}
+			if report.RowsMoved != len(tt.results) || report.Remaining {
+				t.Errorf("Run() = %+v, want %d rows moved and none remaining", report, len(tt.results))
+			}
+
+			rows, err := job.DB.Query(`SELECT granularity, bucket_start, checks, up_checks, down_checks, throttled_checks, p95_response_time
+				FROM uptime_rollups ORDER BY granularity, bucket_start`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rows.Close()
+			var got []rollupRow
+			for rows.Next() {
+				var r rollupRow
+				if err := rows.Scan(&r.granularity, &r.start, &r.checks, &r.up, &r.down, &r.throttled, &r.p95); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, r)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("got buckets %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("bucket %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRunMergesLateResults(t *testing.T) {
+	job := openTestJob(t)
+	t.Setenv("ROLLUP_TIMEZONE", "UTC")
+	ctx := context.Background()
+	cutoff := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	insert := func(responseTime int64, n int) {
+		t.Helper()
+		for range n {
+			if _, err := job.DB.Exec(`INSERT INTO uptime_checks (website_id, region, status, response_time, status_code, checked_at)
+				VALUES ('w1', 'eu', 'up', ?, 200, '2024-03-01T10:30:00.000Z')`, responseTime); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	insert(1000, 1)
+	if _, err := job.Run(ctx, cutoff); err != nil {
+		t.Fatalf("first Run() = %v", err)
+	}
+	// The rest of the hour arrives after it was rolled up.
+	insert(100, 39)
+	if _, err := job.Run(ctx, cutoff); err != nil {
+		t.Fatalf("second Run() = %v", err)
+	}
+
+	rows, err := job.DB.Query(`SELECT granularity, checks, latency_count, p95_response_time FROM uptime_rollups ORDER BY granularity`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	buckets := 0
+	for rows.Next() {
+		var (
+			granularity        string
+			checks, count, p95 int
+		)
+		if err := rows.Scan(&granularity, &checks, &count, &p95); err != nil {
+			t.Fatal(err)
+		}
+		buckets++
+		if checks != 40 || count != 40 || p95 != 100 {
+			t.Errorf("%s bucket has %d checks, %d latencies and p95 %d, want 40, 40 and 100", granularity, checks, count, p95)
+		}
+	}
+	if buckets != 2 {
+		t.Errorf("got %d buckets, want an hour and a day", buckets)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	h := histogram{}
+	for _, ms := range []int64{5, 99, 101, 1234, 56789} {
+		h.add(ms)
+	}
+	for _, tt := range []struct {
+		p    float64
+		want int64
+	}{
+		{20, 5}, {40, 99}, {60, 110}, {80, 1300}, {95, 57000},
+	} {
+		if got, ok := h.percentile(tt.p); !ok || got != tt.want {
+			t.Errorf("percentile(%v) = %d, %v, want %d", tt.p, got, ok, tt.want)
+		}
+	}
+	if _, ok := (histogram{}).percentile(95); ok {
+		t.Error("percentile() of an empty histogram reported a value")
+	}
+}
+
+func TestRunHoldsLease(t *testing.T) {
+	job := openTestJob(t)
+	ctx := context.Background()
+
+	release, err := job.acquire(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Run(ctx, time.Now()); !errors.Is(err, ErrRunning) {
+		t.Fatalf("Run() while leased = %v, want ErrRunning", err)
+	}
+
+	release()
+	if _, err := job.Run(ctx, time.Now()); err != nil {
+		t.Fatalf("Run() after release = %v", err)
+	}
+	if _, err := job.Run(ctx, time.Now()); err != nil {
+		t.Fatalf("second Run() = %v", err)
+	}
+
+	if _, err := job.DB.Exec(`INSERT INTO job_leases (name, holder, expires_at) VALUES ('rollup', 'crashed', '2000-01-01T00:00:00.000Z')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Run(ctx, time.Now()); err != nil {
+		t.Fatalf("Run() with a lapsed lease = %v", err)
+	}
+}
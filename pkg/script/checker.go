@@ -0,0 +1,64 @@
+package script
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/outcome"
+)
+
+// Checker runs target.Script as a check.
+type Checker struct {
+	Limits Limits
+}
+
+func init() {
+	check.Register("script", Checker{Limits: DefaultLimits})
+}
+
+func (c Checker) Validate(target check.Target) error {
+	if target.Script == "" {
+		return &check.TargetError{Field: "script", Message: "is required for script checks"}
+	}
+	return check.ValidateProxy(target)
+}
+
+func (c Checker) Check(ctx context.Context, target check.Target) check.Result {
+	start := time.Now()
+	scripted, err := Run(ctx, target, c.Limits)
+
+	result := check.Result{
+		WebsiteID:    target.WebsiteID,
+		URL:          target.URL,
+		ResponseTime: time.Since(start).Milliseconds(),
+	}
+
+	if err != nil {
+		logging.From(ctx).Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Script check failed")
+		result.Status = "down"
+		result.StatusCode = 0
+		result.Message = truncate(err.Error())
+	} else {
+		result.Status = scripted.Status
+		result.StatusCode = scripted.StatusCode
+		result.Message = truncate(scripted.Message)
+	}
+	if result.Status != outcome.Up {
+		result.FailureReason = outcome.ReasonScript
+	}
+
+	return result
+}
+
+// maxMessageBytes bounds the message a script can put on its result.
+const maxMessageBytes = 512
+
+func truncate(msg string) string {
+	if len(msg) <= maxMessageBytes {
+		return msg
+	}
+	return strings.ToValidUTF8(msg[:maxMessageBytes], "")
+}
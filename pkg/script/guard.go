@@ -0,0 +1,24 @@
+package script
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"monitor-workder/pkg/check"
+)
+
+// client sends script requests through the same proxy as other checks.
+// Unless SCRIPT_ALLOW_PRIVATE_ADDRESSES is set, scripts run with a
+// check.WithGuard context, so connections and redirects to internal
+// addresses are refused like those of tenants' checks.
+var client = func() *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = check.Proxy
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		return check.Dial(ctx, dialer, network, address)
+	}
+	return &http.Client{Transport: transport, CheckRedirect: check.CheckRedirect}
+}()
@@ -0,0 +1,11 @@
+package script
+
+import "syscall"
+
+// limitMemory caps the data segment, which on Linux covers every private
+// writable mapping and so the Go heap, but not the address space the Go
+// runtime reserves up front.
+func limitMemory(bytes int64) error {
+	limit := uint64(bytes)
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit})
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package script
+
+// limitMemory only has a hard cap on Linux. Elsewhere the child relies on
+// the soft limit set with debug.SetMemoryLimit.
+func limitMemory(bytes int64) error {
+	return nil
+}
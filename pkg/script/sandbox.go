@@ -0,0 +1,149 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+)
+
+// Scripts run in a copy of the worker's own executable started with
+// sandboxEnv set. The child reads a sandboxRequest from stdin before any
+// other package in the binary initializes, caps its heap, runs the script
+// and writes a sandboxResponse to stdout. A script that exceeds the cap
+// crashes only the child.
+const sandboxEnv = "UPTIQ_SCRIPT_SANDBOX"
+
+type sandboxRequest struct {
+	Target       check.Target `json:"target"`
+	Limits       Limits       `json:"limits"`
+	AllowPrivate bool         `json:"allowPrivate"`
+}
+
+type sandboxResponse struct {
+	Outcome Outcome `json:"outcome"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// init turns the process into a sandbox child when started by
+// runSandboxed. Packages that import this one, like the HTTP server,
+// initialize after it, so the child never connects to the database.
+func init() {
+	if os.Getenv(sandboxEnv) == "1" {
+		os.Exit(serveSandbox(os.Stdin, os.Stdout))
+	}
+}
+
+func runSandboxed(ctx context.Context, target check.Target, limits Limits, allowPrivate bool) (Outcome, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Outcome{}, fmt.Errorf("locating worker executable: %w", err)
+	}
+	input, err := json.Marshal(sandboxRequest{Target: target, Limits: limits, AllowPrivate: allowPrivate})
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	// The child enforces the timeout itself; the extra second covers its
+	// startup before the process is killed.
+	ctx, cancel := context.WithTimeout(ctx, limits.Timeout+time.Second)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, exe)
+	// The child does not load the config file, so it is handed the
+	// operator's proxy, which guarded connections may still reach.
+	cmd.Env = append(os.Environ(), sandboxEnv+"=1", "CHECK_PROXY_URL="+config.String("CHECK_PROXY_URL", ""))
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &limitedBuffer{buf: &stderr, n: 4096}
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return Outcome{}, fmt.Errorf("script timed out: %w", ctx.Err())
+		}
+		msg := stderr.String()
+		if limits.MaxMemoryBytes > 0 && outOfMemory(msg) {
+			return Outcome{}, fmt.Errorf("script exceeded %d bytes of memory", limits.MaxMemoryBytes)
+		}
+		return Outcome{}, fmt.Errorf("script sandbox failed: %w: %s", err, firstLine(msg))
+	}
+
+	var resp sandboxResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return Outcome{}, fmt.Errorf("reading script sandbox output: %w", err)
+	}
+	if resp.Error != "" {
+		return resp.Outcome, errors.New(resp.Error)
+	}
+	return resp.Outcome, nil
+}
+
+// serveSandbox runs the script described on r and writes the response to
+// w, returning the process exit code.
+func serveSandbox(r io.Reader, w io.Writer) int {
+	var req sandboxRequest
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		fmt.Fprintln(os.Stderr, "script sandbox:", err)
+		return 2
+	}
+
+	if req.Limits.MaxMemoryBytes > 0 {
+		debug.SetMemoryLimit(req.Limits.MaxMemoryBytes)
+		if err := limitMemory(req.Limits.MaxMemoryBytes); err != nil {
+			fmt.Fprintln(os.Stderr, "script sandbox:", err)
+			return 2
+		}
+	}
+
+	var resp sandboxResponse
+	outcome, err := run(context.Background(), req.Target, req.Limits, req.AllowPrivate)
+	resp.Outcome = outcome
+	if err != nil {
+		resp.Error = err.Error()
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		return 2
+	}
+	return 0
+}
+
+// limitedBuffer keeps the first n bytes written to it, so a crashing
+// child cannot grow the worker's memory through its stderr.
+type limitedBuffer struct {
+	buf *bytes.Buffer
+	n   int
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	if room := l.n - l.buf.Len(); room > 0 {
+		l.buf.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// outOfMemory reports whether a crashed child's stderr shows it ran into
+// the memory cap. Depending on where the allocation fails the runtime
+// either reports that it is out of memory or faults on the memory it
+// could not map; the interpreter cannot fault otherwise.
+func outOfMemory(stderr string) bool {
+	return strings.Contains(stderr, "out of memory") ||
+		strings.Contains(stderr, "cannot allocate memory") ||
+		strings.HasPrefix(stderr, "SIGSEGV") ||
+		strings.Contains(stderr, "unexpected signal during runtime execution")
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
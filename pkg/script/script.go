@@ -0,0 +1,256 @@
+// Package script runs user-supplied Starlark check scripts in a sandbox.
+//
+// A script must define a check() function. It may call http.get and
+// http.post a bounded number of times and must return either a status
+// string ("up", "degraded", "throttled" or "down") or a dict with "status" and optional
+// "statusCode" and "message" keys.
+//
+//	def check():
+//	    resp = http.get("https://example.com/health")
+//	    if resp.status_code != 200 or "ok" not in resp.body:
+//	        return {"status": "down", "statusCode": resp.status_code}
+//	    return "up"
+//
+// Starlark has no file, network or clock access of its own, and the legacy
+// dialect used here disallows while loops and recursion, so runtime is
+// bounded by the step limit and wall-clock timeout. A single step can
+// still allocate up to a gigabyte, so scripts run in a child process whose
+// memory is capped at MaxMemoryBytes. Since scripts may come from tenants,
+// the http builtins refuse loopback, private and link-local addresses
+// unless SCRIPT_ALLOW_PRIVATE_ADDRESSES is true.
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+)
+
+type Limits struct {
+	MaxScriptBytes   int
+	MaxSteps         uint64
+	Timeout          time.Duration
+	MaxHTTPCalls     int
+	MaxResponseBytes int64
+	MaxMemoryBytes   int64
+}
+
+var DefaultLimits = Limits{
+	MaxScriptBytes:   16 << 10,
+	MaxSteps:         1_000_000,
+	Timeout:          10 * time.Second,
+	MaxHTTPCalls:     5,
+	MaxResponseBytes: 1 << 20,
+	MaxMemoryBytes:   256 << 20,
+}
+
+type Outcome struct {
+	Status     string
+	StatusCode int
+	Message    string
+}
+
+var validStatuses = map[string]bool{"up": true, "degraded": true, "throttled": true, "down": true}
+
+// Run executes target.Script under limits and returns the status reported
+// by its check() function. Requests identify themselves and use a proxy
+// as configured for target.
+//
+// The script runs in a sandboxed child process unless SCRIPT_SANDBOX is
+// "inline", which runs it in the worker itself without a memory cap.
+func Run(ctx context.Context, target check.Target, limits Limits) (Outcome, error) {
+	if len(target.Script) > limits.MaxScriptBytes {
+		return Outcome{}, fmt.Errorf("script exceeds %d bytes", limits.MaxScriptBytes)
+	}
+
+	// The child process does not load the config file, so settings that
+	// come from it are resolved here.
+	target.UserAgent = check.UserAgent(target)
+	if target.SendCheckID == nil {
+		send := config.String("CHECK_ID_HEADER", "false") == "true"
+		target.SendCheckID = &send
+	}
+	allowPrivate := config.String("SCRIPT_ALLOW_PRIVATE_ADDRESSES", "false") == "true" && !check.Guarded(ctx)
+
+	if config.String("SCRIPT_SANDBOX", "process") == "inline" {
+		return run(ctx, target, limits, allowPrivate)
+	}
+	return runSandboxed(ctx, target, limits, allowPrivate)
+}
+
+// run executes target.Script in the current process.
+func run(ctx context.Context, target check.Target, limits Limits, allowPrivate bool) (Outcome, error) {
+	ctx = check.WithIdentity(check.WithProxy(ctx, target.Proxy), target)
+	if !allowPrivate {
+		ctx = check.WithGuard(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, limits.Timeout)
+	defer cancel()
+
+	thread := &starlark.Thread{
+		Name: "check",
+		// print() would otherwise write to the worker's stderr.
+		Print: func(*starlark.Thread, string) {},
+	}
+	thread.SetMaxExecutionSteps(limits.MaxSteps)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	module := &httpModule{ctx: ctx, limits: limits}
+	predeclared := starlark.StringDict{
+		"http": &starlarkstruct.Module{
+			Name: "http",
+			Members: starlark.StringDict{
+				"get":  starlark.NewBuiltin("http.get", module.get),
+				"post": starlark.NewBuiltin("http.post", module.post),
+			},
+		},
+	}
+
+	globals, err := starlark.ExecFile(thread, "check.star", target.Script, predeclared)
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	fn, ok := globals["check"].(starlark.Callable)
+	if !ok {
+		return Outcome{}, errors.New("script does not define check()")
+	}
+
+	value, err := starlark.Call(thread, fn, nil, nil)
+	if err != nil {
+		return Outcome{}, err
+	}
+
+	return toOutcome(value)
+}
+
+func toOutcome(value starlark.Value) (Outcome, error) {
+	var outcome Outcome
+
+	switch v := value.(type) {
+	case starlark.String:
+		outcome.Status = string(v)
+	case *starlark.Dict:
+		status, found, _ := v.Get(starlark.String("status"))
+		if !found {
+			return outcome, errors.New("check() result is missing \"status\"")
+		}
+		s, ok := starlark.AsString(status)
+		if !ok {
+			return outcome, errors.New("check() \"status\" must be a string")
+		}
+		outcome.Status = s
+
+		if code, found, _ := v.Get(starlark.String("statusCode")); found {
+			n, err := starlark.AsInt32(code)
+			if err != nil {
+				return outcome, fmt.Errorf("check() \"statusCode\": %w", err)
+			}
+			outcome.StatusCode = n
+		}
+		if msg, found, _ := v.Get(starlark.String("message")); found {
+			outcome.Message, _ = starlark.AsString(msg)
+		}
+	default:
+		return outcome, fmt.Errorf("check() returned %s, want string or dict", value.Type())
+	}
+
+	if !validStatuses[outcome.Status] {
+		return outcome, fmt.Errorf("check() returned unknown status %q", outcome.Status)
+	}
+	return outcome, nil
+}
+
+type httpModule struct {
+	ctx    context.Context
+	limits Limits
+	calls  int
+}
+
+func (m *httpModule) get(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url string
+	var headers *starlark.Dict
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "headers?", &headers); err != nil {
+		return nil, err
+	}
+	return m.do(http.MethodGet, url, "", headers)
+}
+
+func (m *httpModule) post(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var url, body string
+	var headers *starlark.Dict
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "body?", &body, "headers?", &headers); err != nil {
+		return nil, err
+	}
+	return m.do(http.MethodPost, url, body, headers)
+}
+
+func (m *httpModule) do(method, url, body string, headers *starlark.Dict) (starlark.Value, error) {
+	if m.calls >= m.limits.MaxHTTPCalls {
+		return nil, fmt.Errorf("script exceeded %d HTTP calls", m.limits.MaxHTTPCalls)
+	}
+	m.calls++
+
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("unsupported URL %q", url)
+	}
+
+	req, err := http.NewRequestWithContext(m.ctx, method, url, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if err := check.AllowedHost(m.ctx, req.URL.Hostname()); err != nil {
+		return nil, err
+	}
+	check.Identify(req)
+	if headers != nil {
+		for _, item := range headers.Items() {
+			k, _ := starlark.AsString(item[0])
+			v, _ := starlark.AsString(item[1])
+			req.Header.Set(k, v)
+		}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, m.limits.MaxResponseBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	respHeaders := starlark.NewDict(len(resp.Header))
+	for k := range resp.Header {
+		respHeaders.SetKey(starlark.String(strings.ToLower(k)), starlark.String(resp.Header.Get(k)))
+	}
+
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"status_code": starlark.MakeInt(resp.StatusCode),
+		"body":        starlark.String(data),
+		"headers":     respHeaders,
+		"elapsed_ms":  starlark.MakeInt64(time.Since(start).Milliseconds()),
+	}), nil
+}
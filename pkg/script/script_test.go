@@ -0,0 +1,101 @@
+package script
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"monitor-workder/pkg/check"
+)
+
+func TestRun(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer backend.Close()
+
+	probe := `
+def check():
+    resp = http.get("` + backend.URL + `")
+    return {"status": "up" if resp.body == "ok" else "down", "statusCode": resp.status_code}
+`
+
+	tests := []struct {
+		name         string
+		script       string
+		allowPrivate bool
+		want         Outcome
+		wantErr      string
+	}{
+		{
+			name:   "status string",
+			script: "def check():\n    return \"degraded\"\n",
+			want:   Outcome{Status: "degraded"},
+		},
+		{
+			name:   "dict with message",
+			script: "def check():\n    return {\"status\": \"down\", \"statusCode\": 500, \"message\": \"queue stuck\"}\n",
+			want:   Outcome{Status: "down", StatusCode: 500, Message: "queue stuck"},
+		},
+		{
+			name:    "unknown status",
+			script:  "def check():\n    return \"sideways\"\n",
+			wantErr: "unknown status",
+		},
+		{
+			name:    "missing check",
+			script:  "x = 1\n",
+			wantErr: "does not define check()",
+		},
+		{
+			name:    "step limit",
+			script:  "def check():\n    for i in range(10000000):\n        pass\n    return \"up\"\n",
+			wantErr: "too many steps",
+		},
+		{
+			name:    "private address refused",
+			script:  probe,
+			wantErr: "may not connect",
+		},
+		{
+			name:         "private address allowed",
+			script:       probe,
+			allowPrivate: true,
+			want:         Outcome{Status: "up", StatusCode: 200},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.allowPrivate {
+				t.Setenv("SCRIPT_ALLOW_PRIVATE_ADDRESSES", "true")
+			}
+			got, err := Run(context.Background(), check.Target{Script: tt.script}, DefaultLimits)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Run() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunMemoryLimit(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("memory is only hard-capped on Linux")
+	}
+	_, err := Run(context.Background(), check.Target{Script: "def check():\n    x = \"x\" * (1 << 29)\n    return \"up\"\n"}, DefaultLimits)
+	if err == nil || !strings.Contains(err.Error(), "memory") {
+		t.Fatalf("Run() error = %v, want memory limit error", err)
+	}
+}
@@ -0,0 +1,931 @@
+// Package server is the worker's HTTP API. It backs both the serverless
+// entrypoint in api/index.go and the long-running cmd/server, and is set
+// up from its init function when first imported.
+package server
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/rs/zerolog/log"
+
+	_ "monitor-workder/plugins"
+
+	"monitor-workder/pkg/admin"
+	"monitor-workder/pkg/auth"
+	"monitor-workder/pkg/cache"
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/content"
+	"monitor-workder/pkg/coordinate"
+	"monitor-workder/pkg/dashboard"
+	"monitor-workder/pkg/heartbeat"
+	"monitor-workder/pkg/incident"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/outcome"
+	"monitor-workder/pkg/plugin"
+	"monitor-workder/pkg/ratelimit"
+	"monitor-workder/pkg/resource"
+	"monitor-workder/pkg/rollup"
+	"monitor-workder/pkg/shadow"
+	"monitor-workder/pkg/sse"
+	"monitor-workder/pkg/stats"
+	"monitor-workder/pkg/storage"
+	"monitor-workder/pkg/tracing"
+	"monitor-workder/pkg/version"
+	"monitor-workder/pkg/worker"
+)
+
+type Capabilities struct {
+	CheckTypes   []string            `json:"checkTypes"`
+	MaxBatchSize int                 `json:"maxBatchSize"`
+	MaxTimeoutMs int64               `json:"maxTimeoutMs"`
+	Regions      []string            `json:"regions"`
+	Integrations map[string][]string `json:"integrations"`
+}
+
+var (
+	db        *sql.DB
+	checkRuns *storage.CheckRuns
+	bulk      *admin.Bulk
+	retention *rollup.Job
+	history   *stats.Store
+	limiter   *ratelimit.Limiter
+	summaries *cache.LRU[stats.UptimeSummary]
+	mux       = http.NewServeMux()
+)
+
+func loadEnv() error {
+	log.Info().Msg("Loading environment variables")
+	if err := godotenv.Load(".env"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func init() {
+	if err := loadEnv(); err != nil {
+		log.Warn().Err(err).Msg("Error loading environment variables from .env")
+	}
+	if err := config.Load(); err != nil {
+		log.Fatal().Err(err).Msg("Unable to load config file")
+	}
+	logging.Configure()
+
+	var (
+		err     error
+		dialect storage.Dialect
+	)
+	db, dialect, err = storage.Open()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to connect to database")
+	}
+
+	if err := storage.Configure(db, dialect); err != nil {
+		log.Fatal().Err(err).Msg("Unable to configure result sinks")
+	}
+	shadow.Configure(db, dialect)
+	heartbeat.Configure(db, dialect)
+	incident.Configure(db, dialect)
+	content.Configure(db, dialect)
+	auth.Configure(db, dialect)
+	if err := tracing.Configure(context.Background()); err != nil {
+		log.Error().Err(err).Msg("Unable to configure tracing")
+	}
+	checkRuns = &storage.CheckRuns{
+		DB:      db,
+		Dialect: dialect,
+		Window:  config.Duration("IDEMPOTENCY_WINDOW", 10*time.Minute),
+	}
+
+	history = &stats.Store{DB: db, Dialect: dialect}
+	retention = &rollup.Job{DB: db, Dialect: dialect}
+	limiter = ratelimit.New()
+	summaries = cache.New[stats.UptimeSummary](config.Int("SUMMARY_CACHE_SIZE", 1000))
+	if dialect == storage.Postgres {
+		bulk = &admin.Bulk{DB: db}
+	}
+
+	mux.HandleFunc("GET /v1/capabilities", handleCapabilities)
+	mux.HandleFunc("GET /v1/diff", handleDiff)
+	mux.HandleFunc("GET /websites/{id}/summary", handleSummary)
+	mux.HandleFunc("POST /v1/admin/bulk", handleBulk)
+	mux.HandleFunc("POST /v1/maintenance/rollup", handleRollup)
+	mux.HandleFunc("GET /v1/admin/metrics", handleMetrics)
+	mux.HandleFunc("POST /v1/validate", handleValidate)
+	mux.Handle("GET /dashboard", &dashboard.Dashboard{DB: db, Dialect: dialect})
+	mux.HandleFunc("GET /version", handleVersion)
+	mux.HandleFunc("GET /v1/outcomes", handleOutcomes)
+	mux.HandleFunc("POST /v1/coordinate", handleCoordinate)
+	mux.HandleFunc("POST /heartbeat/{monitorId}", handleHeartbeat)
+	for _, kind := range resource.Kinds(db, dialect) {
+		registerResource(kind)
+	}
+	mux.HandleFunc("/", handleChecks)
+}
+
+// authorize authenticates r and applies the caller's rate limit, writing
+// the error response itself when the request may not proceed.
+func authorize(w http.ResponseWriter, r *http.Request) bool {
+	_, ok := authenticate(w, r)
+	return ok
+}
+
+// authorizeAdmin is like authorize but also requires the caller's key to
+// be listed in ADMIN_KEY_IDS.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	caller, ok := authenticate(w, r)
+	if !ok {
+		return false
+	}
+	if !slices.Contains(config.List("ADMIN_KEY_IDS"), caller.KeyID) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// authorizeWebsites requires a tenant caller to own every website in ids,
+// answering 403 with the ones it does not.
+func authorizeWebsites(w http.ResponseWriter, r *http.Request, caller auth.Caller, ids []uuid.UUID) bool {
+	foreign, err := caller.Foreign(r.Context(), ids)
+	if err != nil {
+		logging.From(r.Context()).Error().Err(err).Msg("Error checking website ownership")
+		http.Error(w, "Error checking website ownership", http.StatusInternalServerError)
+		return false
+	}
+	if len(foreign) > 0 {
+		writeJSON(w, http.StatusForbidden, map[string]any{
+			"error":      "Websites do not belong to the caller's tenant",
+			"websiteIds": foreign,
+		})
+		return false
+	}
+	return true
+}
+
+func authenticate(w http.ResponseWriter, r *http.Request) (auth.Caller, bool) {
+	caller, err := auth.Authenticate(r)
+	if errors.Is(err, auth.ErrThrottled) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return caller, false
+	}
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return caller, false
+	}
+	if caller.Legacy {
+		w.Header().Set("Deprecation", "true")
+	}
+
+	if ok, wait := limiter.Allow(caller.KeyID); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return caller, false
+	}
+	return caller, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	response, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "Error generating response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
+
+// Close flushes buffered results and traces and closes the database. It
+// is called once the HTTP server has stopped handling requests.
+func Close(ctx context.Context) error {
+	err := storage.Flush(ctx)
+	tracing.Shutdown(ctx)
+	return errors.Join(err, db.Close())
+}
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	info := version.Get()
+	w.Header().Set("X-Worker-Version", info.Version)
+	w.Header().Set("X-Worker-API-Version", strconv.Itoa(info.APIVersion))
+
+	if required := r.Header.Get("X-Require-Features"); required != "" {
+		var features []string
+		for _, f := range strings.Split(required, ",") {
+			features = append(features, strings.TrimSpace(f))
+		}
+		if missing := version.Missing(features); len(missing) > 0 {
+			http.Error(w, "Unsupported features: "+strings.Join(missing, ", "), http.StatusPreconditionFailed)
+			return
+		}
+	}
+
+	requestID := r.Header.Get("X-Request-Id")
+	if len(requestID) == 0 || len(requestID) > 128 || strings.ContainsFunc(requestID, unicode.IsControl) {
+		requestID = uuid.NewString()
+	}
+	w.Header().Set("X-Request-Id", requestID)
+	r = r.WithContext(logging.WithRequestID(r.Context(), requestID))
+
+	r, span := tracing.StartRequest(r)
+	defer func() {
+		span.End()
+		tracing.Flush(context.WithoutCancel(r.Context()))
+	}()
+	if id := tracing.TraceID(r.Context()); id != "" {
+		w.Header().Set("X-Trace-Id", id)
+	}
+
+	mux.ServeHTTP(w, r)
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, version.Get())
+}
+
+// handleOutcomes lists the status and failure reason codes results can
+// carry. Like /version it needs no credentials.
+func handleOutcomes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"statuses":       outcome.Statuses,
+		"failureReasons": outcome.Reasons,
+	})
+}
+
+func handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+
+	integrations := plugin.Capabilities()
+	delete(integrations, "checkers")
+
+	regions := config.List("REGIONS")
+	if regions == nil {
+		regions = []string{}
+	}
+
+	writeJSON(w, http.StatusOK, Capabilities{
+		CheckTypes:   check.Types(),
+		MaxBatchSize: worker.MaxBatchSize(),
+		MaxTimeoutMs: worker.CheckTimeout().Milliseconds(),
+		Regions:      regions,
+		Integrations: integrations,
+	})
+}
+
+// handleCoordinate runs a batch on every peer worker at the same instant
+// and returns each region's results side by side.
+func handleCoordinate(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	var req coordinate.Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Peers are called with the coordinator's own key, so ownership has to
+	// be checked here rather than by them.
+	websiteIDs := make([]uuid.UUID, len(req.Urls))
+	for i, raw := range req.Urls {
+		var target struct {
+			WebsiteID uuid.UUID `json:"websiteId"`
+		}
+		if err := json.Unmarshal(raw, &target); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		websiteIDs[i] = target.WebsiteID
+	}
+	if !authorizeWebsites(w, r, caller, websiteIDs) {
+		return
+	}
+
+	resp, err := coordinate.Fanout(r.Context(), caller, req)
+	if errors.Is(err, coordinate.ErrNoPeers) {
+		http.Error(w, "Coordination is not configured", http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		logging.From(r.Context()).Error().Err(err).Msg("Error coordinating check")
+		http.Error(w, "Error coordinating check", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleDiff compares results between the before and after windows given
+// as beforeStart, beforeEnd, afterStart and afterEnd (RFC 3339), for one
+// website when websiteId is set or across all of them otherwise.
+func handleDiff(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	var websiteID uuid.UUID
+	if id := q.Get("websiteId"); id != "" {
+		var err error
+		if websiteID, err = uuid.Parse(id); err != nil {
+			http.Error(w, "Invalid websiteId", http.StatusBadRequest)
+			return
+		}
+	}
+	if caller.TenantID != "" && websiteID == uuid.Nil {
+		http.Error(w, "websiteId is required", http.StatusBadRequest)
+		return
+	}
+	if !authorizeWebsites(w, r, caller, []uuid.UUID{websiteID}) {
+		return
+	}
+
+	before, err := parseWindow(q, "before")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	after, err := parseWindow(q, "after")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var summaries [2]stats.Summary
+	for i, window := range []stats.Window{before, after} {
+		samples, truncated, err := history.Samples(r.Context(), websiteID, window)
+		if err != nil {
+			logging.From(r.Context()).Error().Err(err).Msg("Error reading results")
+			http.Error(w, "Error reading results", http.StatusInternalServerError)
+			return
+		}
+		summaries[i] = stats.Summarize(window, samples)
+		summaries[i].Truncated = truncated
+	}
+
+	writeJSON(w, http.StatusOK, stats.Compare(summaries[0], summaries[1]))
+}
+
+// handleSummary reports a website's uptime, latency and incident count
+// over the period given as 24h (the default), 7d or 30d. Summaries are
+// cached in memory for SUMMARY_CACHE_TTL.
+func handleSummary(w http.ResponseWriter, r *http.Request) {
+	caller, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	websiteID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid website ID", http.StatusBadRequest)
+		return
+	}
+	if !authorizeWebsites(w, r, caller, []uuid.UUID{websiteID}) {
+		return
+	}
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "24h"
+	}
+	length, ok := stats.Periods[period]
+	if !ok {
+		http.Error(w, "period must be one of 24h, 7d, 30d", http.StatusBadRequest)
+		return
+	}
+
+	ttl := config.Duration("SUMMARY_CACHE_TTL", time.Minute)
+	key := websiteID.String() + "/" + period
+	summary, age, ok := summaries.Get(key, ttl)
+	if !ok {
+		now := time.Now().UTC()
+		summary, err = history.Uptime(r.Context(), websiteID, stats.Window{Start: now.Add(-length), End: now})
+		if err != nil {
+			logging.From(r.Context()).Error().Err(err).Str("websiteId", websiteID.String()).Msg("Error summarising uptime")
+			http.Error(w, "Error summarising uptime", http.StatusInternalServerError)
+			return
+		}
+		summaries.Put(key, summary)
+	}
+
+	cache.SetHeaders(w, ttl, age)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"websiteId": websiteID,
+		"period":    period,
+		"summary":   summary,
+	})
+}
+
+// parseWindow reads the <prefix>Start and <prefix>End query parameters.
+func parseWindow(q url.Values, prefix string) (stats.Window, error) {
+	var window stats.Window
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{prefix + "Start", &window.Start}, {prefix + "End", &window.End}} {
+		t, err := time.Parse(time.RFC3339, q.Get(p.name))
+		if err != nil {
+			return window, fmt.Errorf("%s must be an RFC 3339 timestamp", p.name)
+		}
+		*p.dst = t
+	}
+	if !window.Start.Before(window.End) {
+		return window, fmt.Errorf("%sStart must be before %sEnd", prefix, prefix)
+	}
+	return window, nil
+}
+
+// handleBulk applies an admin operation to every website matching its
+// selector. Unless the body sets "dryRun": false it only previews them.
+func handleBulk(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	if bulk == nil {
+		http.Error(w, "Bulk operations require a Postgres database", http.StatusNotImplemented)
+		return
+	}
+
+	var req admin.Request
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp, err := bulk.Apply(r.Context(), req)
+	if err != nil {
+		logging.From(r.Context()).Error().Err(err).Msg("Error applying bulk operation")
+		http.Error(w, "Error applying bulk operation", http.StatusInternalServerError)
+		return
+	}
+	if !resp.DryRun {
+		logging.From(r.Context()).Info().Str("action", req.Action.Type).Int("count", resp.Count).Msg("Applied bulk operation")
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleValidate checks a declarative config without applying it, so CI
+// can reject a broken config before it is merged. Problems are reported
+// in the body; the status is 200 whenever the config could be read.
+func handleValidate(w http.ResponseWriter, r *http.Request) {
+	if !authorize(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 4<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	problems := resource.ValidateConfig(body)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"valid":    len(problems) == 0,
+		"problems": problems,
+	})
+}
+
+// handleRollup rolls up and deletes raw results past retention. Each call
+// does a bounded amount of work; callers such as a cron job repeat it
+// while the response reports remaining rows. It answers 409 while another
+// rollup, such as the scheduler's, is running.
+func handleRollup(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+
+	report, err := retention.Run(r.Context(), rollup.Cutoff())
+	if errors.Is(err, rollup.ErrRunning) {
+		http.Error(w, "Rollup already running", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		logging.From(r.Context()).Error().Err(err).Msg("Error rolling up results")
+		http.Error(w, "Error rolling up results", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// handleMetrics reports the database connection pool and the insert
+// latency and errors of each result sink.
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !authorizeAdmin(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"pool":  storage.Pool(db),
+		"sinks": storage.Metrics(),
+	})
+}
+
+// handleHeartbeat records a ping from a push-based monitor. Jobs ping it
+// without signing, so the unguessable monitor ID is the only credential;
+// pings are rate limited per monitor and rejected for IDs that are not
+// registered heartbeat monitors.
+func handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	monitorID, err := uuid.Parse(r.PathValue("monitorId"))
+	if err != nil {
+		http.Error(w, "Invalid monitor ID", http.StatusBadRequest)
+		return
+	}
+
+	if ok, wait := limiter.Allow("heartbeat:" + monitorID.String()); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+		return
+	}
+
+	err = heartbeat.Record(r.Context(), monitorID)
+	if errors.Is(err, heartbeat.ErrUnknownMonitor) {
+		http.Error(w, "Unknown heartbeat monitor", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		logging.From(r.Context()).Error().Err(err).Str("monitorId", monitorID.String()).Msg("Error recording heartbeat")
+		http.Error(w, "Error recording heartbeat", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleChecks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+
+	caller, ok := authenticate(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req, err := worker.ParseRequest(body)
+	var verr *worker.ValidationError
+	if errors.As(err, &verr) {
+		writeJSON(w, http.StatusBadRequest, map[string]any{
+			"error":    "Invalid request",
+			"problems": verr.Problems,
+		})
+		return
+	}
+
+	websiteIDs := make([]uuid.UUID, len(req.Urls))
+	for i, target := range req.Urls {
+		websiteIDs[i] = target.WebsiteID
+	}
+	if !authorizeWebsites(w, r, caller, websiteIDs) {
+		return
+	}
+
+	// Everything below is tied to the caller: if it disconnects or the
+	// platform cancels the invocation, in-flight checks and writes abort.
+	ctx := r.Context()
+
+	if dry, err := strconv.ParseBool(r.URL.Query().Get("dry_run")); err == nil && dry {
+		req.DryRun = true
+	}
+	if req.DryRun {
+		ctx = storage.WithDryRun(ctx)
+	}
+	if req.Guarded || caller.TenantID != "" {
+		ctx = check.WithGuard(ctx)
+	}
+
+	// A dry run stores nothing, so it is not idempotent either: repeating
+	// it simply checks the targets again.
+	checkRunID := r.Header.Get("Idempotency-Key")
+	if checkRunID == "" {
+		checkRunID = req.CheckRunID
+	}
+	if req.DryRun {
+		checkRunID = ""
+	}
+
+	runKey := caller.IdempotencyKey(checkRunID)
+	if checkRunID != "" {
+		cached, claimed, err := checkRuns.Claim(ctx, runKey)
+		if errors.Is(err, storage.ErrCheckRunInProgress) {
+			http.Error(w, "A request with this idempotency key is already in progress", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Error claiming idempotency key")
+			http.Error(w, "Error checking idempotency key", http.StatusInternalServerError)
+			return
+		}
+		if !claimed && sse.Accepts(r) {
+			replayEvents(w, cached)
+			return
+		}
+		if !claimed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(http.StatusOK)
+			w.Write(cached)
+			return
+		}
+	}
+
+	var (
+		events *sse.Writer
+		emit   func(check.Result)
+	)
+	if sse.Accepts(r) {
+		events = sse.New(w)
+		emit = func(result check.Result) {
+			if err := events.Event("result", result); err != nil {
+				logging.From(ctx).Error().Err(err).Msg("Error streaming result")
+			}
+		}
+	}
+
+	var (
+		resultList []check.Result
+		startedAt  time.Time
+	)
+	if err := req.Wait(ctx); err == nil {
+		startedAt = time.Now()
+		resultList = worker.Stream(ctx, req.Region, checkRunID, req.Urls, emit)
+	}
+
+	if ctx.Err() != nil {
+		if checkRunID != "" {
+			if err := checkRuns.Release(context.WithoutCancel(ctx), runKey); err != nil {
+				logging.From(ctx).Error().Err(err).Msg("Error releasing idempotency key")
+			}
+		}
+		return
+	}
+
+	summary := stats.SummarizeInvocation(req.Region, startedAt, resultList)
+	if !req.DryRun {
+		if err := storage.Flush(ctx); err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Error flushing results")
+		}
+		if err := history.RecordInvocation(ctx, summary); err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Error recording invocation summary")
+		}
+	}
+
+	var payload any = resultList
+	if req.Summary {
+		payload = map[string]any{"results": resultList, "summary": summary}
+	}
+	response, err := json.Marshal(payload)
+	if err != nil {
+		if events == nil {
+			http.Error(w, "Error generating response", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if checkRunID != "" {
+		if err := checkRuns.Store(ctx, runKey, response); err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Error storing idempotent response")
+		}
+	}
+
+	if events != nil {
+		events.Event("done", map[string]any{"count": len(resultList), "summary": summary})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(response)
+}
+
+// registerResource exposes CRUD for kind under /v1/<kind>. Writes to an
+// existing resource must send the ETag from a previous read in If-Match.
+// A PUT with If-None-Match: * creates the resource under a caller-chosen
+// ID, and a POST with an Idempotency-Key always resolves to the same ID.
+func registerResource(kind resource.Kind) {
+	base := "/v1/" + kind.Name
+
+	mux.HandleFunc("GET "+base, func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		list, err := kind.Store.List(r.Context())
+		if err != nil {
+			writeResourceError(w, r, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+
+	mux.HandleFunc("POST "+base, func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		spec, ok := readSpec(w, r, kind)
+		if !ok {
+			return
+		}
+		id := uuid.New()
+		if key := r.Header.Get("Idempotency-Key"); key != "" {
+			id = resource.IDForKey(kind.Name, key)
+		}
+		createResource(w, r, kind, id, spec)
+	})
+
+	mux.HandleFunc("GET "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		id, ok := resourceID(w, r)
+		if !ok {
+			return
+		}
+		res, err := kind.Store.Get(r.Context(), id)
+		if err != nil {
+			writeResourceError(w, r, err)
+			return
+		}
+		writeResource(w, http.StatusOK, res)
+	})
+
+	mux.HandleFunc("PUT "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		id, ok := resourceID(w, r)
+		if !ok {
+			return
+		}
+		spec, ok := readSpec(w, r, kind)
+		if !ok {
+			return
+		}
+
+		if r.Header.Get("If-None-Match") == "*" {
+			createResource(w, r, kind, id, spec)
+			return
+		}
+		version, ok := ifMatch(w, r)
+		if !ok {
+			return
+		}
+		res, err := kind.Store.Update(r.Context(), id, version, spec)
+		if err != nil {
+			writeResourceError(w, r, err)
+			return
+		}
+		writeResource(w, http.StatusOK, res)
+	})
+
+	mux.HandleFunc("DELETE "+base+"/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !authorizeAdmin(w, r) {
+			return
+		}
+		id, ok := resourceID(w, r)
+		if !ok {
+			return
+		}
+		version, ok := ifMatch(w, r)
+		if !ok {
+			return
+		}
+		if err := kind.Store.Delete(r.Context(), id, version); err != nil {
+			writeResourceError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func createResource(w http.ResponseWriter, r *http.Request, kind resource.Kind, id uuid.UUID, spec json.RawMessage) {
+	res, created, err := kind.Store.Create(r.Context(), id, spec)
+	if err != nil {
+		writeResourceError(w, r, err)
+		return
+	}
+	status := http.StatusOK
+	if created {
+		w.Header().Set("Location", "/v1/"+kind.Name+"/"+id.String())
+		status = http.StatusCreated
+	}
+	writeResource(w, status, res)
+}
+
+func readSpec(w http.ResponseWriter, r *http.Request, kind resource.Kind) (json.RawMessage, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	spec, err := kind.Normalize(body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return nil, false
+	}
+	return spec, true
+}
+
+func resourceID(w http.ResponseWriter, r *http.Request) (uuid.UUID, bool) {
+	id, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return id, false
+	}
+	return id, true
+}
+
+// ifMatch reads the version from a strong If-Match ETag, which writes to
+// existing resources require.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int, bool) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		http.Error(w, "If-Match is required", http.StatusPreconditionRequired)
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil {
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		return 0, false
+	}
+	return version, true
+}
+
+func writeResource(w http.ResponseWriter, status int, res resource.Resource) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(res.Version)))
+	writeJSON(w, status, res)
+}
+
+func writeResourceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, resource.ErrNotFound):
+		http.Error(w, "Not found", http.StatusNotFound)
+	case errors.Is(err, resource.ErrVersionMismatch):
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+	case errors.Is(err, resource.ErrConflict):
+		http.Error(w, "A different resource already exists with this ID", http.StatusConflict)
+	default:
+		logging.From(r.Context()).Error().Err(err).Msg("Error accessing resource")
+		http.Error(w, "Error accessing resource", http.StatusInternalServerError)
+	}
+}
+
+// replayEvents streams a stored idempotent response as if its checks had
+// just completed. The response is a bare result list, or an object with
+// the results and their summary when the run asked for one.
+func replayEvents(w http.ResponseWriter, cached []byte) {
+	var stored struct {
+		Results []check.Result    `json:"results"`
+		Summary *stats.Invocation `json:"summary"`
+	}
+	var err error
+	if bytes.HasPrefix(bytes.TrimSpace(cached), []byte("{")) {
+		err = json.Unmarshal(cached, &stored)
+	} else {
+		err = json.Unmarshal(cached, &stored.Results)
+	}
+	if err != nil {
+		http.Error(w, "Error reading stored response", http.StatusInternalServerError)
+		return
+	}
+	results := stored.Results
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	events := sse.New(w)
+	for _, result := range results {
+		events.Event("result", result)
+	}
+	done := map[string]any{"count": len(results)}
+	if stored.Summary != nil {
+		done["summary"] = stored.Summary
+	}
+	events.Event("done", done)
+}
@@ -0,0 +1,128 @@
+// Package shadow runs candidate checker implementations alongside the
+// official ones and records where they disagree, so changes to check
+// semantics can be validated on live traffic before cutover. Only the
+// official result is ever stored or returned.
+//
+// Shadowing is enabled per check type with the shadow_<type> feature flag,
+// e.g. FEATURE_FLAGS="shadow_http=25".
+package shadow
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/flags"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/plugin"
+	"monitor-workder/pkg/storage"
+)
+
+type Discrepancy struct {
+	CheckType string
+	Official  check.Result
+	Candidate check.Result
+}
+
+var (
+	candidates = plugin.NewRegistry[check.Checker]("shadows")
+
+	db      *sql.DB
+	dialect storage.Dialect
+)
+
+func init() {
+	Register(check.DefaultType, check.HTTPv2{})
+}
+
+// Register makes candidate the shadow implementation for checkType.
+func Register(checkType string, candidate check.Checker) {
+	candidates.Register(checkType, candidate)
+}
+
+// Configure sets the database discrepancies are recorded in. Until it is
+// called discrepancies are only logged.
+func Configure(conn *sql.DB, d storage.Dialect) {
+	db, dialect = conn, d
+}
+
+// Check runs target with official and, if shadowing is enabled for the
+// target, with the registered candidate at the same time. It returns the
+// official result.
+func Check(ctx context.Context, official check.Checker, target check.Target) check.Result {
+	checkType := target.CheckType
+	if checkType == "" {
+		checkType = check.DefaultType
+	}
+
+	candidate, ok := candidates.Lookup(checkType)
+	if !ok || !flags.Enabled("shadow_"+checkType, target.WebsiteID.String()) {
+		return official.Check(ctx, target)
+	}
+
+	var (
+		wg     sync.WaitGroup
+		shadow check.Result
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		shadow = candidate.Check(ctx, target)
+	}()
+	result := official.Check(ctx, target)
+	wg.Wait()
+
+	// The official checker may itself have routed to the candidate
+	// implementation, in which case there is nothing to compare.
+	if result.Engine != "" && result.Engine == shadow.Engine {
+		return result
+	}
+
+	if differs(result, shadow) {
+		record(ctx, Discrepancy{CheckType: checkType, Official: result, Candidate: shadow})
+	}
+	return result
+}
+
+func differs(official, candidate check.Result) bool {
+	if official.Status != candidate.Status || official.StatusCode != candidate.StatusCode {
+		return true
+	}
+	delta := official.ResponseTime - candidate.ResponseTime
+	if delta < 0 {
+		delta = -delta
+	}
+	tolerance := config.Duration("SHADOW_LATENCY_TOLERANCE", 250*time.Millisecond)
+	return delta > tolerance.Milliseconds()
+}
+
+func record(ctx context.Context, d Discrepancy) {
+	logging.From(ctx).Warn().
+		Str("websiteId", d.Official.WebsiteID.String()).
+		Str("checkType", d.CheckType).
+		Str("officialStatus", d.Official.Status).
+		Str("candidateStatus", d.Candidate.Status).
+		Int64("officialResponseTime", d.Official.ResponseTime).
+		Int64("candidateResponseTime", d.Candidate.ResponseTime).
+		Msg("Shadow check discrepancy")
+
+	if db == nil || storage.DryRun(ctx) {
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, dialect.Rebind(
+		`INSERT INTO check_discrepancies (
+			website_id, check_type,
+			official_engine, official_status, official_status_code, official_response_time,
+			candidate_engine, candidate_status, candidate_status_code, candidate_response_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`),
+		d.Official.WebsiteID.String(), d.CheckType,
+		d.Official.Engine, d.Official.Status, d.Official.StatusCode, d.Official.ResponseTime,
+		d.Candidate.Engine, d.Candidate.Status, d.Candidate.StatusCode, d.Candidate.ResponseTime,
+	); err != nil {
+		logging.From(ctx).Error().Err(err).Msg("Error recording shadow discrepancy")
+	}
+}
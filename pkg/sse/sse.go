@@ -0,0 +1,41 @@
+// Package sse writes Server-Sent Events responses.
+package sse
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type Writer struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// Accepts reports whether r asked for an event stream.
+func Accepts(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// New starts an event stream on w. Headers must be set before calling it.
+func New(w http.ResponseWriter) *Writer {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	return &Writer{w: w, rc: http.NewResponseController(w)}
+}
+
+// Event sends v as JSON under the given event name and flushes it to the
+// client immediately.
+func (s *Writer) Event(name string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", name, data); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
@@ -0,0 +1,73 @@
+package stats
+
+import (
+	"maps"
+	"slices"
+)
+
+// Diff compares two summaries, typically either side of a deploy.
+type Diff struct {
+	Before Summary `json:"before"`
+	After  Summary `json:"after"`
+
+	// StatusShare is the change in each status's share of checks, in
+	// percentage points.
+	StatusShare map[string]float64 `json:"statusShare"`
+
+	// Latency is After minus Before, present when both windows have
+	// latency data.
+	Latency *Percentiles `json:"latency,omitempty"`
+
+	// NewFailureClasses appear only after; ResolvedFailureClasses only
+	// before.
+	NewFailureClasses      []string `json:"newFailureClasses"`
+	ResolvedFailureClasses []string `json:"resolvedFailureClasses"`
+}
+
+func Compare(before, after Summary) Diff {
+	d := Diff{
+		Before:                 before,
+		After:                  after,
+		StatusShare:            map[string]float64{},
+		NewFailureClasses:      []string{},
+		ResolvedFailureClasses: []string{},
+	}
+
+	statuses := slices.Sorted(maps.Keys(before.Statuses))
+	for status := range after.Statuses {
+		if !slices.Contains(statuses, status) {
+			statuses = append(statuses, status)
+		}
+	}
+	for _, status := range statuses {
+		d.StatusShare[status] = share(after.Statuses[status], after.Checks) - share(before.Statuses[status], before.Checks)
+	}
+
+	if before.Latency != nil && after.Latency != nil {
+		d.Latency = &Percentiles{
+			P50: after.Latency.P50 - before.Latency.P50,
+			P90: after.Latency.P90 - before.Latency.P90,
+			P95: after.Latency.P95 - before.Latency.P95,
+			P99: after.Latency.P99 - before.Latency.P99,
+		}
+	}
+
+	for _, class := range slices.Sorted(maps.Keys(after.FailureClasses)) {
+		if _, ok := before.FailureClasses[class]; !ok {
+			d.NewFailureClasses = append(d.NewFailureClasses, class)
+		}
+	}
+	for _, class := range slices.Sorted(maps.Keys(before.FailureClasses)) {
+		if _, ok := after.FailureClasses[class]; !ok {
+			d.ResolvedFailureClasses = append(d.ResolvedFailureClasses, class)
+		}
+	}
+	return d
+}
+
+func share(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(total)
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+)
+
+// Invocation aggregates the results of one batch of checks.
+type Invocation struct {
+	Region    string         `json:"region,omitempty"`
+	CheckedAt time.Time      `json:"checkedAt"`
+	Checks    int            `json:"checks"`
+	Statuses  map[string]int `json:"statuses"`
+
+	// Latency covers only "up" and "degraded" results, as in Summary.
+	Latency *Latency `json:"latency,omitempty"`
+}
+
+// Latency describes response times in milliseconds.
+type Latency struct {
+	Min int64 `json:"min"`
+	Avg int64 `json:"avg"`
+	P50 int64 `json:"p50"`
+	P95 int64 `json:"p95"`
+	Max int64 `json:"max"`
+}
+
+// SummarizeInvocation aggregates results checked in region at checkedAt.
+// Every status is counted, including those with no results.
+func SummarizeInvocation(region string, checkedAt time.Time, results []check.Result) Invocation {
+	inv := Invocation{
+		Region:    region,
+		CheckedAt: checkedAt.UTC(),
+		Checks:    len(results),
+		Statuses:  map[string]int{},
+	}
+	for _, s := range outcome.Statuses {
+		inv.Statuses[s.Code] = 0
+	}
+
+	var (
+		latencies []int64
+		sum       int64
+	)
+	for _, r := range results {
+		inv.Statuses[r.Status]++
+		if r.Status == outcome.Up || r.Status == outcome.Degraded {
+			latencies = append(latencies, r.ResponseTime)
+			sum += r.ResponseTime
+		}
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		inv.Latency = &Latency{
+			Min: latencies[0],
+			Avg: sum / int64(len(latencies)),
+			P50: percentile(latencies, 50),
+			P95: percentile(latencies, 95),
+			Max: latencies[len(latencies)-1],
+		}
+	}
+	return inv
+}
+
+// RecordInvocation stores inv in invocation_summaries when
+// INVOCATION_SUMMARIES is enabled, and does nothing otherwise.
+func (s *Store) RecordInvocation(ctx context.Context, inv Invocation) error {
+	if config.String("INVOCATION_SUMMARIES", "false") != "true" {
+		return nil
+	}
+
+	args := []any{
+		inv.Region, s.Dialect.Time(inv.CheckedAt), inv.Checks,
+		inv.Statuses[outcome.Up], inv.Statuses[outcome.Degraded], inv.Statuses[outcome.Down], inv.Statuses[outcome.Throttled],
+	}
+	if l := inv.Latency; l != nil {
+		args = append(args, l.Min, l.Avg, l.P50, l.P95, l.Max)
+	} else {
+		args = append(args, nil, nil, nil, nil, nil)
+	}
+
+	_, err := s.DB.ExecContext(ctx, s.Dialect.Rebind(`INSERT INTO invocation_summaries
+		(region, checked_at, checks, up_checks, degraded_checks, down_checks, throttled_checks,
+		 min_response_time, avg_response_time, p50_response_time, p95_response_time, max_response_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`), args...)
+	return err
+}
@@ -0,0 +1,164 @@
+// Package stats summarises stored check results over time windows. Rows
+// are fetched with portable queries and aggregated in Go so every
+// supported database gives the same answers.
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+	"monitor-workder/pkg/storage"
+)
+
+type Window struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Sample is one stored check result.
+type Sample struct {
+	Status        string
+	StatusCode    int
+	ResponseTime  int64
+	FailureReason string
+}
+
+// Percentiles of response time in milliseconds.
+type Percentiles struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P95 int64 `json:"p95"`
+	P99 int64 `json:"p99"`
+}
+
+type Summary struct {
+	Window   Window         `json:"window"`
+	Checks   int            `json:"checks"`
+	Statuses map[string]int `json:"statuses"`
+
+	// Latency covers only checks that got a usable response ("up" and
+	// "degraded"); throttled and failed checks would skew it.
+	Latency *Percentiles `json:"latency,omitempty"`
+
+	// FailureClasses counts non-"up" results by FailureClass.
+	FailureClasses map[string]int `json:"failureClasses"`
+
+	// Truncated is set when the window held more than STATS_MAX_ROWS
+	// results and only the most recent ones were summarised.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Store reads results from the uptime_checks table.
+type Store struct {
+	DB      *sql.DB
+	Dialect storage.Dialect
+}
+
+// Samples returns the results checked within w, for websiteID only when
+// it is not uuid.Nil. At most STATS_MAX_ROWS rows are read, newest first;
+// truncated reports whether older ones were left out.
+func (s *Store) Samples(ctx context.Context, websiteID uuid.UUID, w Window) (samples []Sample, truncated bool, err error) {
+	query := `SELECT status, status_code, response_time, failure_reason FROM uptime_checks
+		WHERE checked_at >= $1 AND checked_at < $2`
+	args := []any{s.Dialect.Time(w.Start), s.Dialect.Time(w.End)}
+	if websiteID != uuid.Nil {
+		query += ` AND website_id = $3`
+		args = append(args, websiteID.String())
+	}
+	// One row past the limit tells a full window from a truncated one.
+	maxRows := config.Int("STATS_MAX_ROWS", 100000)
+	query += fmt.Sprintf(` ORDER BY checked_at DESC LIMIT %d`, maxRows+1)
+
+	rows, err := s.DB.QueryContext(ctx, s.Dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if len(samples) == maxRows {
+			truncated = true
+			break
+		}
+		var (
+			sample Sample
+			reason sql.NullString
+		)
+		if err := rows.Scan(&sample.Status, &sample.StatusCode, &sample.ResponseTime, &reason); err != nil {
+			return nil, false, err
+		}
+		sample.FailureReason = reason.String
+		samples = append(samples, sample)
+	}
+	return samples, truncated, rows.Err()
+}
+
+// FailureClass buckets a non-"up" result by its failure reason, except
+// that unexpected status codes become "http_<code>". Rows stored before
+// failure reasons were recorded fall back to "slow" for degraded checks,
+// "throttled", "connection" when no response arrived, and "http_<code>".
+// It returns "" for "up" results.
+func FailureClass(s Sample) string {
+	switch {
+	case s.Status == outcome.Up:
+		return ""
+	case s.FailureReason == outcome.ReasonHTTPStatus && s.StatusCode != 0:
+		return fmt.Sprintf("http_%d", s.StatusCode)
+	case s.FailureReason != "":
+		return s.FailureReason
+	case s.Status == outcome.Degraded:
+		return outcome.ReasonSlow
+	case s.Status == outcome.Throttled:
+		return outcome.ReasonThrottled
+	case s.StatusCode == 0:
+		return outcome.ReasonConnection
+	default:
+		return fmt.Sprintf("http_%d", s.StatusCode)
+	}
+}
+
+// Summarize aggregates samples taken within w.
+func Summarize(w Window, samples []Sample) Summary {
+	summary := Summary{
+		Window:         w,
+		Checks:         len(samples),
+		Statuses:       map[string]int{},
+		FailureClasses: map[string]int{},
+	}
+
+	var latencies []int64
+	for _, s := range samples {
+		summary.Statuses[s.Status]++
+		if class := FailureClass(s); class != "" {
+			summary.FailureClasses[class]++
+		}
+		if s.Status == "up" || s.Status == "degraded" {
+			latencies = append(latencies, s.ResponseTime)
+		}
+	}
+
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		summary.Latency = &Percentiles{
+			P50: percentile(latencies, 50),
+			P90: percentile(latencies, 90),
+			P95: percentile(latencies, 95),
+			P99: percentile(latencies, 99),
+		}
+	}
+	return summary
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted.
+func percentile(sorted []int64, p float64) int64 {
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank-1, 0)]
+}
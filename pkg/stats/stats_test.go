@@ -0,0 +1,26 @@
+package stats
+
+import "testing"
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		name   string
+		sample Sample
+		want   string
+	}{
+		{name: "up", sample: Sample{Status: "up", StatusCode: 200}, want: ""},
+		{name: "stored reason", sample: Sample{Status: "down", FailureReason: "dns"}, want: "dns"},
+		{name: "stored http status", sample: Sample{Status: "down", StatusCode: 503, FailureReason: "http_status"}, want: "http_503"},
+		{name: "stored assertion on degraded", sample: Sample{Status: "degraded", StatusCode: 200, FailureReason: "header_assertion"}, want: "header_assertion"},
+		{name: "legacy degraded", sample: Sample{Status: "degraded", StatusCode: 200}, want: "slow"},
+		{name: "legacy no response", sample: Sample{Status: "down"}, want: "connection"},
+		{name: "legacy http status", sample: Sample{Status: "down", StatusCode: 500}, want: "http_500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FailureClass(tt.sample); got != tt.want {
+				t.Errorf("FailureClass() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
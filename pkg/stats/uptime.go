@@ -0,0 +1,115 @@
+package stats
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Periods are the lookback windows accepted by Uptime, by name.
+var Periods = map[string]time.Duration{
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+// UptimeSummary is a website's availability over a window. Uptime and
+// latency follow the rollup job's definitions: degraded checks count as
+// up, and throttled checks count towards neither.
+type UptimeSummary struct {
+	Window          Window   `json:"window"`
+	Checks          int      `json:"checks"`
+	UptimePct       *float64 `json:"uptimePct"`
+	AvgResponseTime *float64 `json:"avgResponseTime"`
+
+	// P95ResponseTime is exact while the window only covers raw
+	// results. Once it reaches into rolled-up hours it is the largest
+	// p95 involved, an upper bound.
+	P95ResponseTime *int64 `json:"p95ResponseTime"`
+
+	// Incidents counts incidents opened within the window.
+	Incidents int `json:"incidents"`
+
+	// Truncated is set when the raw results exceeded STATS_MAX_ROWS and
+	// only the most recent ones were counted.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// Uptime summarises websiteID's results within w, combining raw results
+// with the hourly rollups of those already past retention.
+func (s *Store) Uptime(ctx context.Context, websiteID uuid.UUID, w Window) (UptimeSummary, error) {
+	summary := UptimeSummary{Window: w}
+
+	samples, truncated, err := s.Samples(ctx, websiteID, w)
+	summary.Truncated = truncated
+	if err != nil {
+		return summary, err
+	}
+	var (
+		up, throttled int
+		latencySum    int64
+		latencies     []int64
+		p95           sql.NullInt64
+	)
+	for _, sample := range samples {
+		switch sample.Status {
+		case "up", "degraded":
+			up++
+			latencies = append(latencies, sample.ResponseTime)
+			latencySum += sample.ResponseTime
+		case "throttled":
+			throttled++
+		}
+	}
+	summary.Checks = len(samples)
+	latencyCount := len(latencies)
+	if latencyCount > 0 {
+		slices.Sort(latencies)
+		p95 = sql.NullInt64{Int64: percentile(latencies, 95), Valid: true}
+	}
+
+	var (
+		rolledChecks, rolledUp, rolledThrottled, rolledLatencyCount sql.NullInt64
+		rolledLatencySum, rolledP95                                 sql.NullInt64
+	)
+	err = s.DB.QueryRowContext(ctx, s.Dialect.Rebind(
+		`SELECT SUM(checks), SUM(up_checks), SUM(throttled_checks), SUM(latency_count), SUM(latency_sum),
+			MAX(p95_response_time)
+		FROM uptime_rollups
+		WHERE website_id = $1 AND granularity = 'hour' AND bucket_start >= $2 AND bucket_start < $3`),
+		websiteID.String(), s.Dialect.Time(w.Start), s.Dialect.Time(w.End),
+	).Scan(&rolledChecks, &rolledUp, &rolledThrottled, &rolledLatencyCount, &rolledLatencySum, &rolledP95)
+	if err != nil {
+		return summary, err
+	}
+	summary.Checks += int(rolledChecks.Int64)
+	up += int(rolledUp.Int64)
+	throttled += int(rolledThrottled.Int64)
+	latencyCount += int(rolledLatencyCount.Int64)
+	latencySum += rolledLatencySum.Int64
+	if rolledP95.Valid && (!p95.Valid || rolledP95.Int64 > p95.Int64) {
+		p95 = rolledP95
+	}
+
+	if counted := summary.Checks - throttled; counted > 0 {
+		pct := math.Round(float64(up)*10000/float64(counted)) / 100
+		summary.UptimePct = &pct
+	}
+	if latencyCount > 0 {
+		avg := math.Round(float64(latencySum)*10/float64(latencyCount)) / 10
+		summary.AvgResponseTime = &avg
+	}
+	if p95.Valid {
+		summary.P95ResponseTime = &p95.Int64
+	}
+
+	err = s.DB.QueryRowContext(ctx, s.Dialect.Rebind(
+		`SELECT COUNT(*) FROM incidents WHERE website_id = $1 AND opened_at >= $2 AND opened_at < $3`),
+		websiteID.String(), s.Dialect.Time(w.Start), s.Dialect.Time(w.End),
+	).Scan(&summary.Incidents)
+	return summary, err
+}
@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
+)
+
+var ErrCheckRunInProgress = errors.New("check run in progress")
+
+// sweepEvery is how many claims pass, on average, between sweeps of keys
+// that have left the window.
+const sweepEvery = 100
+
+// CheckRuns records idempotency keys for check dispatches so retried
+// dispatches can be answered from the first run's response.
+type CheckRuns struct {
+	DB      *sql.DB
+	Dialect Dialect
+	Window  time.Duration
+}
+
+// Claim reserves key for this invocation. If the key was already claimed
+// within the window it returns the stored response instead, or
+// ErrCheckRunInProgress if that invocation has not finished. About one
+// claim in sweepEvery also deletes every other key that has expired.
+func (c *CheckRuns) Claim(ctx context.Context, key string) (cached []byte, claimed bool, err error) {
+	now := time.Now().UTC()
+
+	expire, args := `DELETE FROM check_runs WHERE id = $1 AND created_at < $2`, []any{key, now.Add(-c.Window)}
+	if rand.IntN(sweepEvery) == 0 {
+		expire, args = `DELETE FROM check_runs WHERE created_at < $1`, args[1:]
+	}
+	if _, err := c.DB.ExecContext(ctx, c.Dialect.Rebind(expire), args...); err != nil {
+		return nil, false, err
+	}
+
+	insert := `INSERT INTO check_runs (id, created_at) VALUES ($1, $2) ON CONFLICT (id) DO NOTHING`
+	if c.Dialect == MySQL {
+		insert = `INSERT IGNORE INTO check_runs (id, created_at) VALUES ($1, $2)`
+	}
+	res, err := c.DB.ExecContext(ctx, c.Dialect.Rebind(insert), key, now)
+	if err != nil {
+		return nil, false, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if n == 1 {
+		return nil, true, nil
+	}
+
+	if err = c.DB.QueryRowContext(ctx, c.Dialect.Rebind(
+		`SELECT response FROM check_runs WHERE id = $1`), key).Scan(&cached); err != nil {
+		return nil, false, err
+	}
+	if cached == nil {
+		return nil, false, ErrCheckRunInProgress
+	}
+	return cached, false, nil
+}
+
+// Store saves the response for a claimed key.
+func (c *CheckRuns) Store(ctx context.Context, key string, response []byte) error {
+	_, err := c.DB.ExecContext(ctx, c.Dialect.Rebind(
+		`UPDATE check_runs SET response = $1 WHERE id = $2`), string(response), key)
+	return err
+}
+
+// Release drops an unfinished claim so the key can be retried immediately.
+func (c *CheckRuns) Release(ctx context.Context, key string) error {
+	_, err := c.DB.ExecContext(ctx, c.Dialect.Rebind(
+		`DELETE FROM check_runs WHERE id = $1 AND response IS NULL`), key)
+	return err
+}
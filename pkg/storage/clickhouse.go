@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/logging"
+)
+
+// ClickHouse buffers results in memory and writes them in batches through
+// ClickHouse's HTTP interface. Batches are sent when they reach the batch
+// size, on a timer, or when Flush is called.
+type ClickHouse struct {
+	endpoint  *url.URL
+	table     string
+	batchSize int
+	client    *http.Client
+	metrics   insertMetrics
+
+	mu      sync.Mutex
+	pending []clickHouseRow
+	full    chan struct{}
+	flushMu sync.Mutex
+}
+
+type clickHouseRow struct {
+	WebsiteID    string `json:"website_id"`
+	Status       string `json:"status"`
+	ResponseTime int64  `json:"response_time"`
+	StatusCode   int    `json:"status_code"`
+	CheckRunID   string `json:"check_run_id"`
+	Engine       string `json:"engine"`
+	CreatedAt    string `json:"created_at"`
+
+	ProviderIncidents []string `json:"provider_incidents"`
+	ContentLength     int64    `json:"content_length"`
+	ContentType       string   `json:"content_type"`
+	BodySHA256        string   `json:"body_sha256"`
+	Confirmed         *bool    `json:"confirmed"`
+	Region            string   `json:"region"`
+	ContentSHA256     string   `json:"content_sha256"`
+	ContentChanged    bool     `json:"content_changed"`
+	FailureReason     string   `json:"failure_reason"`
+}
+
+// NewClickHouse returns a sink writing to table at endpoint, an HTTP(S) URL
+// whose user info, if any, is sent as basic auth.
+func NewClickHouse(endpoint, table string, batchSize int, interval time.Duration) (*ClickHouse, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ClickHouse URL: %w", err)
+	}
+
+	c := &ClickHouse{
+		endpoint:  u,
+		table:     table,
+		batchSize: batchSize,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		full:      make(chan struct{}, 1),
+	}
+	go c.loop(interval)
+	return c, nil
+}
+
+func (c *ClickHouse) Write(ctx context.Context, result check.Result) error {
+	row := clickHouseRow{
+		WebsiteID:    result.WebsiteID.String(),
+		Status:       result.Status,
+		ResponseTime: result.ResponseTime,
+		StatusCode:   result.StatusCode,
+		CheckRunID:   result.CheckRunID,
+		Engine:       result.Engine,
+		CreatedAt:    result.CheckedAt.UTC().Format("2006-01-02 15:04:05.000"),
+
+		ProviderIncidents: result.IncidentProviders(),
+		ContentLength:     result.ContentLength,
+		ContentType:       result.ContentType,
+		BodySHA256:        result.BodyHash,
+		Region:            result.Region,
+		ContentSHA256:     contentHash(result),
+		ContentChanged:    result.ContentChanged,
+		FailureReason:     result.FailureReason,
+	}
+	if result.Confirmation != nil {
+		row.Confirmed = &result.Confirmation.Confirmed
+	}
+
+	c.mu.Lock()
+	c.pending = append(c.pending, row)
+	full := len(c.pending) >= c.batchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.full <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (c *ClickHouse) loop(interval time.Duration) {
+	ctx := context.Background()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-c.full:
+		}
+		if err := c.Flush(ctx); err != nil {
+			logging.From(ctx).Error().Err(err).Msg("Error flushing results to ClickHouse")
+		}
+	}
+}
+
+// Flush sends every buffered result. Rows from a failed batch are put back
+// at the front of the buffer, up to ten batches' worth, and retried on the
+// next flush.
+func (c *ClickHouse) Flush(ctx context.Context) error {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	c.mu.Lock()
+	rows := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	err := c.insert(ctx, rows)
+	c.metrics.record(time.Since(start), err)
+	if err != nil {
+		c.mu.Lock()
+		c.pending = append(rows, c.pending...)
+		if limit := 10 * c.batchSize; len(c.pending) > limit {
+			dropped := len(c.pending) - limit
+			c.pending = c.pending[dropped:]
+			logging.From(ctx).Error().Int("dropped", dropped).Msg("ClickHouse buffer full, dropping oldest results")
+		}
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Metrics reports the latency and errors of this sink's batch inserts.
+// Writes only append to the buffer, so each insert is a whole batch.
+func (c *ClickHouse) Metrics() InsertMetrics {
+	return c.metrics.snapshot()
+}
+
+func (c *ClickHouse) insert(ctx context.Context, rows []clickHouseRow) error {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+
+	u := *c.endpoint
+	u.User = nil
+	q := u.Query()
+	q.Set("query", fmt.Sprintf("INSERT INTO %s FORMAT JSONEachRow", c.table))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), &body)
+	if err != nil {
+		return err
+	}
+	if user := c.endpoint.User; user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
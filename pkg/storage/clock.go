@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+)
+
+// clockProbe estimates how far the worker's wall clock is from the database
+// server's. Serverless instances can resume with a stale clock, which
+// corrupts the ordering of results written by different workers.
+type clockProbe struct {
+	db      *sql.DB
+	dialect Dialect
+
+	mu       sync.Mutex
+	skew     time.Duration
+	probedAt time.Time
+}
+
+func (d Dialect) nowQuery() string {
+	switch d {
+	case MySQL:
+		return `SELECT DATE_FORMAT(UTC_TIMESTAMP(6), '%Y-%m-%dT%H:%i:%s.%fZ')`
+	case SQLite:
+		return `SELECT strftime('%Y-%m-%dT%H:%M:%fZ', 'now')`
+	default:
+		return `SELECT to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`
+	}
+}
+
+// Skew returns the database clock minus the worker clock, re-measuring it at
+// most once a minute. It logs a warning whenever the skew exceeds
+// CLOCK_SKEW_THRESHOLD.
+func (p *clockProbe) Skew(ctx context.Context) (time.Duration, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.probedAt.IsZero() && time.Since(p.probedAt) < time.Minute {
+		return p.skew, nil
+	}
+
+	var raw string
+	sent := time.Now()
+	if err := p.db.QueryRowContext(ctx, p.dialect.nowQuery()).Scan(&raw); err != nil {
+		return 0, err
+	}
+	rtt := time.Since(sent)
+
+	dbNow, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return 0, err
+	}
+
+	// Assume the server read its clock halfway through the round trip.
+	p.skew = dbNow.Sub(sent.Add(rtt / 2))
+	p.probedAt = time.Now()
+
+	threshold := config.Duration("CLOCK_SKEW_THRESHOLD", 2*time.Second)
+	if p.skew > threshold || p.skew < -threshold {
+		logging.From(ctx).Warn().
+			Dur("skew", p.skew).
+			Dur("threshold", threshold).
+			Msg("Worker clock diverges from database clock")
+	}
+	return p.skew, nil
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// InsertMetrics describes the inserts a sink has made since the process
+// started. Latencies are in milliseconds and cover successful inserts only.
+// For sinks that batch writes, an insert is one batch.
+type InsertMetrics struct {
+	Inserts      int64   `json:"inserts"`
+	Errors       int64   `json:"errors"`
+	AvgLatencyMs float64 `json:"avgLatencyMs"`
+	MaxLatencyMs float64 `json:"maxLatencyMs"`
+}
+
+// Metered is implemented by sinks that keep InsertMetrics.
+type Metered interface {
+	Metrics() InsertMetrics
+}
+
+type insertMetrics struct {
+	mu      sync.Mutex
+	inserts int64
+	errors  int64
+	total   time.Duration
+	max     time.Duration
+}
+
+func (m *insertMetrics) record(d time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.errors++
+		return
+	}
+	m.inserts++
+	m.total += d
+	m.max = max(m.max, d)
+}
+
+func (m *insertMetrics) snapshot() InsertMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := InsertMetrics{
+		Inserts:      m.inserts,
+		Errors:       m.errors,
+		MaxLatencyMs: float64(m.max) / float64(time.Millisecond),
+	}
+	if m.inserts > 0 {
+		out.AvgLatencyMs = float64(m.total) / float64(m.inserts) / float64(time.Millisecond)
+	}
+	return out
+}
+
+// Metrics returns the InsertMetrics of every registered sink that keeps
+// them, keyed by sink name.
+func Metrics() map[string]InsertMetrics {
+	metrics := map[string]InsertMetrics{}
+	for _, name := range registry.Names() {
+		s, _ := registry.Lookup(name)
+		if m, ok := s.(Metered); ok {
+			metrics[name] = m.Metrics()
+		}
+	}
+	return metrics
+}
+
+// PoolStats is the JSON form of the database connection pool's
+// sql.DBStats.
+type PoolStats struct {
+	MaxOpenConnections int   `json:"maxOpenConnections"`
+	OpenConnections    int   `json:"openConnections"`
+	InUse              int   `json:"inUse"`
+	Idle               int   `json:"idle"`
+	WaitCount          int64 `json:"waitCount"`
+	WaitDurationMs     int64 `json:"waitDurationMs"`
+	MaxIdleClosed      int64 `json:"maxIdleClosed"`
+	MaxLifetimeClosed  int64 `json:"maxLifetimeClosed"`
+}
+
+// Pool reports the current state of db's connection pool.
+func Pool(db *sql.DB) PoolStats {
+	s := db.Stats()
+	return PoolStats{
+		MaxOpenConnections: s.MaxOpenConnections,
+		OpenConnections:    s.OpenConnections,
+		InUse:              s.InUse,
+		Idle:               s.Idle,
+		WaitCount:          s.WaitCount,
+		WaitDurationMs:     s.WaitDuration.Milliseconds(),
+		MaxIdleClosed:      s.MaxIdleClosed,
+		MaxLifetimeClosed:  s.MaxLifetimeClosed,
+	}
+}
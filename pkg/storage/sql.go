@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/logging"
+)
+
+// Dialect identifies the SQL database behind the worker. Queries are
+// written with Postgres-style $n placeholders and rebound for the others.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+)
+
+var placeholder = regexp.MustCompile(`\$\d+`)
+
+// Rebind rewrites the $n placeholders in query for d. Placeholders must
+// appear in argument order.
+func (d Dialect) Rebind(query string) string {
+	if d == Postgres {
+		return query
+	}
+	return placeholder.ReplaceAllString(query, "?")
+}
+
+// TimeColumn returns an expression that reads column as RFC 3339 text in
+// UTC, which every driver can scan into a string and time.Parse accepts.
+func (d Dialect) TimeColumn(column string) string {
+	switch d {
+	case MySQL:
+		return `DATE_FORMAT(` + column + `, '%Y-%m-%dT%H:%i:%s.%fZ')`
+	case SQLite:
+		return column
+	default:
+		return `to_char(` + column + ` AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.US"Z"')`
+	}
+}
+
+// Time returns t as a query argument for a timestamp column that is read
+// back with TimeColumn. SQLite stores timestamps as text, so t is
+// formatted the same way as its strftime defaults.
+func (d Dialect) Time(t time.Time) any {
+	t = t.UTC()
+	if d == SQLite {
+		return t.Format("2006-01-02T15:04:05.000Z")
+	}
+	return t
+}
+
+// ParseTime parses a timestamp read with TimeColumn. It also accepts the
+// format older SQLite rows were written in before Time was used.
+func ParseTime(raw string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		if t2, err2 := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", raw); err2 == nil {
+			return t2.UTC(), nil
+		}
+	}
+	return t, err
+}
+
+// Open connects to the database selected by DB_DRIVER (postgres, mysql or
+// sqlite) using the DSN in DATABASE_URL. For Postgres the DSN falls back to
+// SECRET_XATA_PG_ENDPOINT.
+//
+// The connection pool is bounded by DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS
+// and DB_CONN_MAX_LIFETIME so that bursts of checks queue for a connection
+// instead of exhausting the server's connection limit.
+func Open() (*sql.DB, Dialect, error) {
+	dialect := Dialect(config.String("DB_DRIVER", string(Postgres)))
+
+	dsn := config.String("DATABASE_URL", "")
+	if dsn == "" && dialect == Postgres {
+		dsn = config.String("SECRET_XATA_PG_ENDPOINT", "")
+	}
+
+	switch dialect {
+	case Postgres, MySQL, SQLite:
+	default:
+		return nil, "", fmt.Errorf("unsupported DB_DRIVER %q", dialect)
+	}
+
+	db, err := sql.Open(string(dialect), dsn)
+	if err != nil {
+		return nil, "", err
+	}
+	db.SetMaxOpenConns(config.Int("DB_MAX_OPEN_CONNS", 10))
+	db.SetMaxIdleConns(config.Int("DB_MAX_IDLE_CONNS", 5))
+	db.SetConnMaxLifetime(config.Duration("DB_CONN_MAX_LIFETIME", 5*time.Minute))
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, "", err
+	}
+	return db, dialect, nil
+}
+
+// SQL writes results to the uptime_checks table. Each row carries both
+// the worker's wall-clock check time (checked_at) and the database's own
+// insert time (created_at), along with the measured skew between the two
+// clocks. The INSERT is prepared on the first write and reused after that.
+type SQL struct {
+	db      *sql.DB
+	dialect Dialect
+	clock   *clockProbe
+	metrics insertMetrics
+
+	mu     sync.Mutex
+	insert *sql.Stmt
+}
+
+func NewSQL(db *sql.DB, dialect Dialect) *SQL {
+	return &SQL{db: db, dialect: dialect, clock: &clockProbe{db: db, dialect: dialect}}
+}
+
+func (s *SQL) Write(ctx context.Context, result check.Result) error {
+	skew := sql.NullInt64{}
+	if d, err := s.clock.Skew(ctx); err != nil {
+		logging.From(ctx).Warn().Err(err).Msg("Unable to measure database clock skew")
+	} else {
+		skew = sql.NullInt64{Int64: d.Milliseconds(), Valid: true}
+	}
+
+	insert, err := s.prepare(ctx)
+	if err != nil {
+		s.metrics.record(0, err)
+		return err
+	}
+
+	start := time.Now()
+	_, err = insert.ExecContext(ctx,
+		result.WebsiteID.String(), result.Status, result.ResponseTime, result.StatusCode,
+		nullString(result.CheckRunID), nullString(result.Engine),
+		s.dialect.Time(result.CheckedAt), skew, nullString(strings.Join(result.IncidentProviders(), ",")),
+		result.ContentLength, nullString(result.ContentType), nullString(result.BodyHash),
+		confirmed(result), nullString(result.Region), nullString(contentHash(result)), result.ContentChanged,
+		nullString(result.FailureReason))
+	s.metrics.record(time.Since(start), err)
+	return err
+}
+
+// prepare returns the prepared INSERT, preparing it if no earlier attempt
+// succeeded.
+func (s *SQL) prepare(ctx context.Context) (*sql.Stmt, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.insert != nil {
+		return s.insert, nil
+	}
+
+	insert, err := s.db.PrepareContext(ctx, s.dialect.Rebind(
+		`INSERT INTO uptime_checks (website_id, status, response_time, status_code, check_run_id, engine,
+			checked_at, clock_skew_ms, provider_incidents, content_length, content_type, body_sha256, confirmed,
+			region, content_sha256, content_changed, failure_reason)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`))
+	if err != nil {
+		return nil, err
+	}
+	s.insert = insert
+	return insert, nil
+}
+
+// Metrics reports the latency and errors of this sink's inserts.
+func (s *SQL) Metrics() InsertMetrics {
+	return s.metrics.snapshot()
+}
+
+// confirmed is NULL unless the result went through confirmation.
+func confirmed(result check.Result) sql.NullBool {
+	if result.Confirmation == nil {
+		return sql.NullBool{}
+	}
+	return sql.NullBool{Bool: result.Confirmation.Confirmed, Valid: true}
+}
+
+// contentHash is the normalized body hash, for targets with a content
+// check.
+func contentHash(result check.Result) string {
+	if result.Content == nil {
+		return ""
+	}
+	return result.Content.Hash
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
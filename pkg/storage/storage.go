@@ -0,0 +1,116 @@
+// Package storage defines the Sink extension point that persists check
+// results, along with the built-in Postgres sink.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/plugin"
+	"monitor-workder/pkg/tracing"
+)
+
+type Sink interface {
+	Write(ctx context.Context, result check.Result) error
+}
+
+// Flusher is implemented by sinks that buffer writes. Short-lived
+// invocations call Flush before returning so buffered results are not lost
+// when the process is frozen.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+var registry = plugin.NewRegistry[Sink]("sinks")
+
+func Register(name string, s Sink) {
+	registry.Register(name, s)
+}
+
+// Write stores result in every registered sink and returns the combined
+// errors of those that failed.
+func Write(ctx context.Context, result check.Result) error {
+	var errs []error
+	for _, name := range registry.Names() {
+		s, _ := registry.Lookup(name)
+		ctx, span := tracing.Tracer.Start(ctx, "storage.write", trace.WithAttributes(
+			attribute.String("sink", name),
+			attribute.String("website.id", result.WebsiteID.String()),
+		))
+		if err := s.Write(ctx, result); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+		span.End()
+	}
+	return errors.Join(errs...)
+}
+
+type dryRunKey struct{}
+
+// WithDryRun marks ctx as a dry run: checks run as usual but their results,
+// and anything derived from them, are not persisted.
+func WithDryRun(ctx context.Context) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, true)
+}
+
+// DryRun reports whether ctx was marked with WithDryRun.
+func DryRun(ctx context.Context) bool {
+	dry, _ := ctx.Value(dryRunKey{}).(bool)
+	return dry
+}
+
+// Flush flushes every registered sink that buffers writes.
+func Flush(ctx context.Context) error {
+	var errs []error
+	for _, name := range registry.Names() {
+		s, _ := registry.Lookup(name)
+		if f, ok := s.(Flusher); ok {
+			if err := f.Flush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Configure registers the result sinks listed in RESULT_SINKS. The name
+// "sql" (the default) refers to the worker's own database; "clickhouse"
+// sends results to the ClickHouse server at CLICKHOUSE_URL.
+func Configure(db *sql.DB, dialect Dialect) error {
+	sinks := config.List("RESULT_SINKS")
+	if sinks == nil {
+		sinks = []string{"sql"}
+	}
+
+	for _, name := range sinks {
+		switch name {
+		case "sql":
+			Register(string(dialect), NewSQL(db, dialect))
+		case "clickhouse":
+			ch, err := NewClickHouse(
+				config.String("CLICKHOUSE_URL", ""),
+				config.String("CLICKHOUSE_TABLE", "uptime_checks"),
+				config.Int("CLICKHOUSE_BATCH_SIZE", 1000),
+				config.Duration("CLICKHOUSE_FLUSH_INTERVAL", time.Second),
+			)
+			if err != nil {
+				return err
+			}
+			Register("clickhouse", ch)
+		default:
+			return fmt.Errorf("unknown result sink %q", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package traceroute traces the network path to a host, MTR-style: each
+// hop is probed TRACEROUTE_PROBES times with ICMP echo requests whose TTL
+// expires there, and its loss and round-trip times are reported.
+//
+// The trace stops at the destination, after TRACEROUTE_MAX_HOPS hops or
+// when TRACEROUTE_TIMEOUT runs out, whichever comes first. Sending raw
+// ICMP needs root or CAP_NET_RAW; without it the report only carries the
+// error.
+package traceroute
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+
+	"monitor-workder/pkg/config"
+)
+
+const probeTimeout = time.Second
+
+// Report is the path to one address.
+type Report struct {
+	Host    string `json:"host"`
+	Address string `json:"address,omitempty"`
+	Reached bool   `json:"reached"`
+	Hops    []Hop  `json:"hops,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Hop summarizes the probes whose TTL expired at one distance. Address is
+// empty when no probe got an answer.
+type Hop struct {
+	TTL     int     `json:"ttl"`
+	Address string  `json:"address,omitempty"`
+	Sent    int     `json:"sent"`
+	Lost    int     `json:"lost"`
+	AvgMs   float64 `json:"avgMs"`
+	BestMs  float64 `json:"bestMs"`
+	WorstMs float64 `json:"worstMs"`
+}
+
+// Trace resolves host to an IPv4 address, or IPv6 when v6 is set, and
+// traces the path to it. Failures are recorded in the report's Error
+// rather than returned, so a partial path is kept.
+func Trace(ctx context.Context, host string, v6 bool) Report {
+	ctx, cancel := context.WithTimeout(ctx, config.Duration("TRACEROUTE_TIMEOUT", 10*time.Second))
+	defer cancel()
+
+	report := Report{Host: host}
+	dst, err := resolve(ctx, host, v6)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	report.Address = dst.String()
+
+	t, err := newTracer(dst)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	defer t.conn.Close()
+
+	probes := max(config.Int("TRACEROUTE_PROBES", 3), 1)
+	for ttl := 1; ttl <= config.Int("TRACEROUTE_MAX_HOPS", 30); ttl++ {
+		hop := Hop{TTL: ttl}
+		var rtts []float64
+		for range probes {
+			if ctx.Err() != nil {
+				break
+			}
+			hop.Sent++
+			from, rtt, reached, err := t.probe(ctx, ttl)
+			if err != nil {
+				report.Error = err.Error()
+				break
+			}
+			if from == nil {
+				hop.Lost++
+				continue
+			}
+			hop.Address = from.String()
+			rtts = append(rtts, rtt)
+			report.Reached = report.Reached || reached
+		}
+		summarize(&hop, rtts)
+		if hop.Sent > 0 {
+			report.Hops = append(report.Hops, hop)
+		}
+		if report.Reached || report.Error != "" {
+			break
+		}
+		if ctx.Err() != nil {
+			report.Error = "traceroute timed out"
+			break
+		}
+	}
+	return report
+}
+
+func resolve(ctx context.Context, host string, v6 bool) (net.IP, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if (a.IP.To4() == nil) == v6 {
+			return a.IP, nil
+		}
+	}
+	return nil, errors.New("no address in the requested IP family")
+}
+
+func summarize(hop *Hop, rtts []float64) {
+	if len(rtts) == 0 {
+		return
+	}
+	hop.BestMs, hop.WorstMs = math.Inf(1), 0
+	var sum float64
+	for _, rtt := range rtts {
+		sum += rtt
+		hop.BestMs = min(hop.BestMs, rtt)
+		hop.WorstMs = max(hop.WorstMs, rtt)
+	}
+	hop.AvgMs = round(sum / float64(len(rtts)))
+	hop.BestMs, hop.WorstMs = round(hop.BestMs), round(hop.WorstMs)
+}
+
+func round(ms float64) float64 {
+	return math.Round(ms*100) / 100
+}
+
+// tracer sends echo requests to dst over a raw ICMP socket. Every trace
+// uses its own echo ID, since the socket sees all ICMP traffic on the host.
+type tracer struct {
+	conn     *icmp.PacketConn
+	dst      *net.IPAddr
+	v6       bool
+	id       int
+	seq      int
+	setTTL   func(int) error
+	echo     icmp.Type
+	reply    icmp.Type
+	exceeded icmp.Type
+}
+
+func newTracer(dst net.IP) (*tracer, error) {
+	t := &tracer{dst: &net.IPAddr{IP: dst}, v6: dst.To4() == nil, id: rand.IntN(math.MaxUint16)}
+	var err error
+	if t.v6 {
+		t.conn, err = icmp.ListenPacket("ip6:ipv6-icmp", "::")
+		if err != nil {
+			return nil, err
+		}
+		t.setTTL = t.conn.IPv6PacketConn().SetHopLimit
+		t.echo, t.reply, t.exceeded = ipv6.ICMPTypeEchoRequest, ipv6.ICMPTypeEchoReply, ipv6.ICMPTypeTimeExceeded
+	} else {
+		t.conn, err = icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+		if err != nil {
+			return nil, err
+		}
+		t.setTTL = t.conn.IPv4PacketConn().SetTTL
+		t.echo, t.reply, t.exceeded = ipv4.ICMPTypeEcho, ipv4.ICMPTypeEchoReply, ipv4.ICMPTypeTimeExceeded
+	}
+	return t, nil
+}
+
+// probe sends one echo request with the given TTL and waits for the
+// matching time-exceeded message or echo reply. from is nil when none
+// arrived in time.
+func (t *tracer) probe(ctx context.Context, ttl int) (from net.IP, rttMs float64, reached bool, err error) {
+	t.seq++
+	seq := t.seq & math.MaxUint16
+	msg := icmp.Message{Type: t.echo, Body: &icmp.Echo{ID: t.id, Seq: seq, Data: []byte("uptiq-traceroute")}}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if err := t.setTTL(ttl); err != nil {
+		return nil, 0, false, err
+	}
+
+	deadline := time.Now().Add(probeTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := t.conn.SetReadDeadline(deadline); err != nil {
+		return nil, 0, false, err
+	}
+
+	sent := time.Now()
+	if _, err := t.conn.WriteTo(b, t.dst); err != nil {
+		return nil, 0, false, err
+	}
+
+	proto := 1
+	if t.v6 {
+		proto = 58
+	}
+	buf := make([]byte, 1500)
+	for {
+		n, peer, err := t.conn.ReadFrom(buf)
+		if errors.Is(err, net.ErrClosed) || isTimeout(err) {
+			return nil, 0, false, nil
+		}
+		if err != nil {
+			return nil, 0, false, err
+		}
+		rtt := float64(time.Since(sent).Microseconds()) / 1000
+
+		rm, err := icmp.ParseMessage(proto, buf[:n])
+		if err != nil {
+			continue
+		}
+		switch {
+		case rm.Type == t.reply:
+			if echo, ok := rm.Body.(*icmp.Echo); ok && echo.ID == t.id && echo.Seq == seq {
+				return peer.(*net.IPAddr).IP, rtt, true, nil
+			}
+		case rm.Type == t.exceeded:
+			if body, ok := rm.Body.(*icmp.TimeExceeded); ok && t.matches(body.Data, seq) {
+				return peer.(*net.IPAddr).IP, rtt, false, nil
+			}
+		}
+	}
+}
+
+// matches reports whether data, the start of the packet that expired,
+// carries this tracer's echo request with sequence number seq.
+func (t *tracer) matches(data []byte, seq int) bool {
+	header := ipv6.HeaderLen
+	if !t.v6 {
+		if len(data) < ipv4.HeaderLen {
+			return false
+		}
+		header = int(data[0]&0x0f) * 4
+	}
+	if len(data) < header+8 {
+		return false
+	}
+	echo := data[header:]
+	return int(echo[4])<<8|int(echo[5]) == t.id && int(echo[6])<<8|int(echo[7]) == seq
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,104 @@
+// Package tracing exports OpenTelemetry spans for requests, checks and
+// result writes over OTLP/HTTP. It is off unless OTEL_EXPORTER_OTLP_ENDPOINT
+// or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT is set; the exporter reads the rest
+// of the standard OTEL_EXPORTER_OTLP_* variables itself. Until Configure
+// succeeds every span is a no-op, so instrumented code needs no checks.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/version"
+)
+
+// Tracer creates the worker's spans. It follows the global provider, so it
+// can be used before Configure runs.
+var Tracer = otel.Tracer("monitor-workder")
+
+var provider *sdktrace.TracerProvider
+
+func init() {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+// Configure installs an OTLP exporter when one is configured.
+func Configure(ctx context.Context) error {
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return err
+	}
+	res := resource.NewSchemaless(
+		attribute.String("service.name", config.String("OTEL_SERVICE_NAME", "monitor-worker")),
+		attribute.String("service.version", version.Get().Version),
+	)
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	log.Info().Msg("Exporting traces over OTLP")
+	return nil
+}
+
+// Flush exports every finished span. Serverless invocations call it before
+// returning, since the platform may freeze the process straight after.
+func Flush(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+	if err := provider.ForceFlush(ctx); err != nil {
+		log.Warn().Err(err).Msg("Error exporting traces")
+	}
+}
+
+// Shutdown flushes and stops the exporter.
+func Shutdown(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(ctx); err != nil {
+		log.Warn().Err(err).Msg("Error shutting down trace exporter")
+	}
+}
+
+// TraceID returns the ID of the trace ctx belongs to, or "" outside one.
+func TraceID(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
+
+// StartRequest starts a server span for r, continuing any trace the caller
+// propagated in a traceparent header, and returns r bound to it.
+func StartRequest(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := Tracer.Start(ctx, r.Method+" "+r.URL.Path,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+	return r.WithContext(ctx), span
+}
+
+// Inject adds ctx's trace to an outgoing request's headers.
+func Inject(ctx context.Context, req *http.Request) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+}
@@ -0,0 +1,106 @@
+// Package version describes the running worker build. Version, Commit and
+// BuildDate are set at link time:
+//
+//	go build -ldflags "-X monitor-workder/pkg/version.Version=v1.4.0 ..."
+package version
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// APIVersion is incremented whenever the check request payload changes in a
+// way that older workers would silently misinterpret.
+const APIVersion = 2
+
+// Features lists the optional request fields and behaviours this worker
+// understands. Orchestrators send X-Require-Features to refuse dispatch to
+// workers that predate a field instead of having it ignored.
+var Features = []string{
+	"checkRunId",
+	"checkType",
+	"confirmations",
+	"content",
+	"dryRun",
+	"eventStream",
+	"executeAt",
+	"expectedHeaders",
+	"expectedStatusCodes",
+	"guarded",
+	"hedgeDelayMs",
+	"holdMs",
+	"idempotencyKey",
+	"intervalSeconds",
+	"ipVersion",
+	"noCache",
+	"options",
+	"providers",
+	"proxy",
+	"script",
+	"sendCheckId",
+	"startTls",
+	"summary",
+	"traceroute",
+	"userAgent",
+}
+
+type Info struct {
+	Version    string   `json:"version"`
+	Commit     string   `json:"commit"`
+	BuildDate  string   `json:"buildDate"`
+	APIVersion int      `json:"apiVersion"`
+	Features   []string `json:"features"`
+}
+
+// Get returns the build info, falling back to the VCS stamp embedded by the
+// Go toolchain or Vercel's deployment environment when no ldflags were set.
+func Get() Info {
+	info := Info{
+		Version:    Version,
+		Commit:     Commit,
+		BuildDate:  BuildDate,
+		APIVersion: APIVersion,
+		Features:   Features,
+	}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			}
+		}
+	}
+	if info.Commit == "" {
+		info.Commit = os.Getenv("VERCEL_GIT_COMMIT_SHA")
+	}
+	return info
+}
+
+// Missing returns the entries of required that this worker does not support.
+func Missing(required []string) []string {
+	supported := make(map[string]bool, len(Features))
+	for _, f := range Features {
+		supported[f] = true
+	}
+
+	var missing []string
+	for _, f := range required {
+		if !supported[f] {
+			missing = append(missing, f)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+
+	"monitor-workder/pkg/cache"
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/outcome"
+)
+
+// recent holds the latest result per target so overlapping batches do not
+// probe the same target twice within RESULT_CACHE_TTL. Like the summary
+// cache it is per process. It is built on first use, after the
+// configuration has been loaded.
+var recent = sync.OnceValue(func() *cache.LRU[check.Result] {
+	return cache.New[check.Result](config.Int("RESULT_CACHE_SIZE", 10000))
+})
+
+// recentKey hashes every setting of target, so targets that differ in
+// expected status codes, headers, options or anything else that can change
+// the result never share an entry. NoCache only decides whether the cache
+// is read and is left out. Options are re-encoded so their key order and
+// spacing do not matter. ok is false when target cannot be encoded.
+func recentKey(target check.Target) (key string, ok bool) {
+	target.NoCache = false
+	if len(target.Options) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(target.Options))
+		dec.UseNumber()
+		var options any
+		if err := dec.Decode(&options); err != nil {
+			return "", false
+		}
+		target.Options, _ = json.Marshal(options)
+	}
+	encoded, err := json.Marshal(target)
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(encoded)
+	return target.WebsiteID.String() + "|" + hex.EncodeToString(sum[:]), true
+}
+
+// cachedResult returns target's last result if it is younger than
+// RESULT_CACHE_TTL, which is off by default. Only "up" and "degraded"
+// results are reused: a failure is always checked again, so an outage is
+// neither hidden nor prolonged by the cache. Targets with NoCache set are
+// always checked.
+func cachedResult(target check.Target) (check.Result, bool) {
+	ttl := config.Duration("RESULT_CACHE_TTL", 0)
+	if ttl <= 0 || target.NoCache {
+		return check.Result{}, false
+	}
+	key, ok := recentKey(target)
+	if !ok {
+		return check.Result{}, false
+	}
+	result, _, ok := recent().Get(key, ttl)
+	if !ok || (result.Status != outcome.Up && result.Status != outcome.Degraded) {
+		return check.Result{}, false
+	}
+	result.Cached = true
+	return result, true
+}
+
+func remember(target check.Target, result check.Result) {
+	if config.Duration("RESULT_CACHE_TTL", 0) <= 0 {
+		return
+	}
+	if key, ok := recentKey(target); ok {
+		recent().Put(key, result)
+	}
+}
@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+)
+
+func TestRecentKey(t *testing.T) {
+	websiteID := uuid.New()
+	base := check.Target{WebsiteID: websiteID, URL: "https://example.com", CheckType: "http"}
+	with := func(change func(*check.Target)) check.Target {
+		target := base
+		change(&target)
+		return target
+	}
+
+	tests := []struct {
+		name   string
+		target check.Target
+		same   bool
+	}{
+		{"identical", base, true},
+		{"noCache ignored", with(func(t *check.Target) { t.NoCache = true }), true},
+		{"other website", with(func(t *check.Target) { t.WebsiteID = uuid.New() }), false},
+		{"other url", with(func(t *check.Target) { t.URL = "https://example.org" }), false},
+		{"expected status codes", with(func(t *check.Target) { t.ExpectedStatusCodes = check.StatusCodes{"204"} }), false},
+		{"expected headers", with(func(t *check.Target) {
+			t.ExpectedHeaders = check.HeaderAssertions{{Name: "Content-Type", Matches: "^text/html"}}
+		}), false},
+		{"proxy", with(func(t *check.Target) { t.Proxy = "socks5://proxy:1080" }), false},
+		{"options", with(func(t *check.Target) { t.Options = json.RawMessage(`{"a":1}`) }), false},
+	}
+
+	want, ok := recentKey(base)
+	if !ok {
+		t.Fatal("recentKey(base) failed")
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := recentKey(tt.target)
+			if !ok {
+				t.Fatal("recentKey() failed")
+			}
+			if (got == want) != tt.same {
+				t.Errorf("recentKey() same as base = %v, want %v", got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestRecentKeyNormalizesOptions(t *testing.T) {
+	a := check.Target{URL: "x", Options: json.RawMessage(`{"b": 2, "a": [1, 2]}`)}
+	b := check.Target{URL: "x", Options: json.RawMessage(`{"a":[1,2],"b":2}`)}
+	keyA, okA := recentKey(a)
+	keyB, okB := recentKey(b)
+	if !okA || !okB || keyA != keyB {
+		t.Errorf("recentKey() = %q, %q for equivalent options, want equal", keyA, keyB)
+	}
+
+	if _, ok := recentKey(check.Target{Options: json.RawMessage(`{`)}); ok {
+		t.Error("recentKey() accepted malformed options")
+	}
+}
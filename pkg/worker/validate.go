@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/provider"
+)
+
+type Problem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a request, so callers can
+// fix a batch in one round trip.
+type ValidationError struct {
+	Problems []Problem `json:"problems"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Field + ": " + p.Message
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Problems = append(e.Problems, Problem{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// MaxBatchSize is the largest number of targets accepted in one request.
+func MaxBatchSize() int {
+	return config.Int("MAX_BATCH_SIZE", 5)
+}
+
+// MaxConfirmations is the most re-checks a target may ask for.
+func MaxConfirmations() int {
+	return config.Int("MAX_CONFIRMATIONS", 3)
+}
+
+// MaxExecuteAtLead is how far in the future a request's executeAt may be.
+// It must leave the invocation enough time to run its checks afterwards.
+func MaxExecuteAtLead() time.Duration {
+	return config.Duration("MAX_EXECUTE_AT_LEAD", 10*time.Second)
+}
+
+// ParseRequest decodes and validates a check request. Any problems are
+// returned together as a *ValidationError.
+func ParseRequest(data []byte) (Request, error) {
+	var raw struct {
+		Region     string            `json:"region"`
+		Urls       []json.RawMessage `json:"urls"`
+		CheckRunID string            `json:"checkRunId"`
+		ExecuteAt  *time.Time        `json:"executeAt"`
+		Summary    bool              `json:"summary"`
+		DryRun     bool              `json:"dryRun"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Request{}, &ValidationError{Problems: []Problem{{Field: "", Message: "body is not valid JSON: " + err.Error()}}}
+	}
+
+	verr := &ValidationError{}
+	req := Request{Region: raw.Region, CheckRunID: raw.CheckRunID, ExecuteAt: raw.ExecuteAt, Summary: raw.Summary, DryRun: raw.DryRun}
+
+	if regions := config.List("REGIONS"); regions != nil && !slices.Contains(regions, raw.Region) {
+		verr.add("region", "must be one of %s", strings.Join(regions, ", "))
+	}
+
+	if raw.ExecuteAt != nil {
+		if lead := MaxExecuteAtLead(); time.Until(*raw.ExecuteAt) > lead {
+			verr.add("executeAt", "must be at most %s in the future", lead)
+		}
+	}
+
+	switch n := len(raw.Urls); {
+	case n == 0:
+		verr.add("urls", "must contain at least one target")
+	case n > MaxBatchSize():
+		verr.add("urls", "must contain at most %d targets", MaxBatchSize())
+	}
+
+	for i, item := range raw.Urls {
+		if target, ok := validateTarget(verr, fmt.Sprintf("urls[%d]", i), item); ok {
+			req.Urls = append(req.Urls, target)
+		}
+	}
+
+	if len(verr.Problems) > 0 {
+		return req, verr
+	}
+	return req, nil
+}
+
+func validateTarget(verr *ValidationError, field string, item json.RawMessage) (check.Target, bool) {
+	var target check.Target
+
+	// Decode the ID on its own first so a malformed UUID is reported
+	// against its field instead of failing the whole target.
+	var id struct {
+		WebsiteID *string `json:"websiteId"`
+	}
+	if err := json.Unmarshal(item, &id); err != nil {
+		verr.add(field, "must be an object")
+		return target, false
+	}
+
+	valid := true
+	switch {
+	case id.WebsiteID == nil || *id.WebsiteID == "":
+		verr.add(field+".websiteId", "is required")
+		valid = false
+	case uuid.Validate(*id.WebsiteID) != nil:
+		verr.add(field+".websiteId", "must be a valid UUID")
+		valid = false
+	}
+	if !valid {
+		return target, false
+	}
+
+	if err := json.Unmarshal(item, &target); err != nil {
+		verr.add(field, "%s", err.Error())
+		return target, false
+	}
+	if target.WebsiteID == uuid.Nil {
+		verr.add(field+".websiteId", "must not be the nil UUID")
+		valid = false
+	}
+
+	maxLen := config.Int("MAX_URL_LENGTH", 2048)
+	switch {
+	case strings.TrimSpace(target.URL) == "":
+		verr.add(field+".url", "is required")
+		valid = false
+	case len(target.URL) > maxLen:
+		verr.add(field+".url", "must be at most %d characters", maxLen)
+		valid = false
+	default:
+		if _, err := url.Parse(target.URL); err != nil {
+			verr.add(field+".url", "is not a valid URL")
+			valid = false
+		}
+	}
+
+	if limit := MaxConfirmations(); target.Confirmations < 0 || target.Confirmations > limit {
+		verr.add(field+".confirmations", "must be between 0 and %d", limit)
+		valid = false
+	}
+
+	for i, name := range target.Providers {
+		if !provider.Known(name) {
+			verr.add(fmt.Sprintf("%s.providers[%d]", field, i), "is not a supported provider")
+			valid = false
+		}
+	}
+
+	if err := check.Validate(target); err != nil {
+		var tv *check.TargetError
+		if errors.As(err, &tv) {
+			verr.add(field+"."+tv.Field, "%s", tv.Message)
+		} else {
+			verr.add(field, "%s", err.Error())
+		}
+		valid = false
+	}
+
+	return target, valid
+}
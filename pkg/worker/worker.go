@@ -0,0 +1,288 @@
+// Package worker runs batches of checks and hands their results to the
+// configured sinks and notifiers.
+package worker
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/config"
+	"monitor-workder/pkg/content"
+	"monitor-workder/pkg/logging"
+	"monitor-workder/pkg/notify"
+	"monitor-workder/pkg/outcome"
+	"monitor-workder/pkg/provider"
+	"monitor-workder/pkg/shadow"
+	"monitor-workder/pkg/storage"
+	"monitor-workder/pkg/traceroute"
+	"monitor-workder/pkg/tracing"
+)
+
+// Request is a batch of checks dispatched by the scheduler, either as an
+// HTTP request body or as a queue message.
+type Request struct {
+	Region     string         `json:"region"`
+	Urls       []check.Target `json:"urls"`
+	CheckRunID string         `json:"checkRunId,omitempty"`
+
+	// ExecuteAt, when set, delays the batch until that instant so workers
+	// in several regions can probe the same targets at the same moment.
+	ExecuteAt *time.Time `json:"executeAt,omitempty"`
+
+	// Summary asks for the results to be returned with their aggregate
+	// stats, as {"results": [...], "summary": {...}}, instead of as a
+	// bare array.
+	Summary bool `json:"summary,omitempty"`
+
+	// DryRun runs the checks and returns their results without storing
+	// them or sending notifications, so new monitor configurations can be
+	// previewed without affecting uptime history.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Guarded keeps the checks from reaching internal addresses, as for a
+	// tenant's batch (see check.WithGuard). Coordinators set it when they
+	// forward a tenant's batch under their own key.
+	Guarded bool `json:"guarded,omitempty"`
+}
+
+// Wait blocks until r's ExecuteAt, returning early with ctx's error if it
+// is cancelled first. Requests without ExecuteAt, or whose ExecuteAt has
+// passed, return immediately.
+func (r Request) Wait(ctx context.Context) error {
+	if r.ExecuteAt == nil {
+		return nil
+	}
+	d := time.Until(*r.ExecuteAt)
+	if d <= 0 {
+		if d < -time.Second {
+			logging.From(ctx).Warn().Dur("late", -d).Msg("Request received after its executeAt")
+		}
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// CheckTimeout bounds how long a single check may run.
+func CheckTimeout() time.Duration {
+	return check.Timeout()
+}
+
+// Run executes targets concurrently, then stores and announces each
+// result. Targets must already have passed check.Validate. Results are
+// returned in completion order.
+func Run(ctx context.Context, region, checkRunID string, targets []check.Target) []check.Result {
+	return Stream(ctx, region, checkRunID, targets, nil)
+}
+
+// Stream is like Run but also passes each result to emit, if non-nil, as
+// soon as it has been stored, without waiting for slower checks.
+//
+// Cancelling ctx aborts the checks still in flight. Results that arrive
+// after cancellation are discarded rather than stored, since an aborted
+// check would otherwise be recorded as a false "down".
+//
+// When ctx is a storage.DryRun every target is checked afresh and results
+// are neither stored, cached nor notified.
+func Stream(ctx context.Context, region, checkRunID string, targets []check.Target, emit func(check.Result)) []check.Result {
+	timeout := CheckTimeout()
+	dryRun := storage.DryRun(ctx)
+
+	var wg sync.WaitGroup
+	results := make(chan check.Result, len(targets))
+
+	for _, target := range targets {
+		if result, ok := cachedResult(target); ok && !dryRun {
+			results <- result
+			continue
+		}
+		checker, err := check.Lookup(target)
+		if err != nil {
+			logging.From(ctx).Error().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Skipping target")
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkID := uuid.NewString()
+			ctx := logging.WithCheckID(ctx, checkID)
+			// A timed-out check has used up its own deadline, so re-checks
+			// and path diagnostics get theirs from the batch instead.
+			batchCtx := ctx
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			ctx, span := tracing.Tracer.Start(ctx, "check", trace.WithAttributes(
+				attribute.String("website.id", target.WebsiteID.String()),
+				attribute.String("url.full", target.URL),
+				attribute.String("check.type", target.CheckType),
+			))
+			defer span.End()
+
+			checkedAt := time.Now()
+			result := shadow.Check(ctx, checker, target)
+			if result.Status == "down" && target.Confirmations > 0 {
+				result = confirm(trace.ContextWithSpan(batchCtx, span), checker, target, result, timeout)
+			}
+			result.CheckID = checkID
+			result.CheckedAt = checkedAt.UTC()
+			result.Path = diagnose(batchCtx, target, result)
+			if err := content.Detect(ctx, target, &result); err != nil {
+				logging.From(ctx).Warn().Err(err).Str("websiteId", target.WebsiteID.String()).Msg("Error comparing response content")
+			}
+			if len(target.Providers) > 0 {
+				result.ProviderIncidents = provider.Active(ctx, target.Providers)
+			}
+			span.SetAttributes(
+				attribute.String("check.status", result.Status),
+				attribute.Int("http.response.status_code", result.StatusCode),
+				attribute.Int64("check.response_time_ms", result.ResponseTime),
+			)
+			if ctx.Err() == nil && !dryRun {
+				remember(target, result)
+			}
+			results <- result
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		resultList []check.Result
+		dropped    int
+	)
+	for result := range results {
+		if ctx.Err() != nil {
+			dropped++
+			continue
+		}
+
+		result.CheckRunID = checkRunID
+		result.Region = region
+		result.RequestID = logging.RequestID(ctx)
+		resultList = append(resultList, result)
+
+		checkCtx := logging.WithCheckID(ctx, result.CheckID)
+		logger := logging.From(checkCtx)
+		logger.Info().
+			Str("websiteId", result.WebsiteID.String()).
+			Str("url", result.URL).
+			Str("status", result.Status).
+			Int("statusCode", result.StatusCode).
+			Int64("responseTime", result.ResponseTime).
+			Bool("cached", result.Cached).
+			Bool("contentChanged", result.ContentChanged).
+			Bool("dryRun", dryRun).
+			Msg("Check completed")
+
+		if !result.Cached && !dryRun {
+			if err := storage.Write(checkCtx, result); err != nil {
+				logger.Error().Err(err).Msg("Error inserting result into database")
+			}
+			notify.Dispatch(checkCtx, notify.Event{Region: region, Result: result})
+		}
+
+		if emit != nil {
+			emit(result)
+		}
+	}
+
+	if dropped > 0 {
+		logging.From(ctx).Warn().Err(ctx.Err()).Int("dropped", dropped).Msg("Check batch cancelled, discarding remaining results")
+	}
+
+	return resultList
+}
+
+// confirm re-runs a failed target up to target.Confirmations times,
+// CONFIRMATION_DELAY apart and each with its own timeout, and returns the
+// first re-check that did not fail. If every re-check fails, the original
+// failure is confirmed; if ctx ends first, including during a re-check, it
+// is returned unconfirmed and marked cancelled.
+func confirm(ctx context.Context, checker check.Checker, target check.Target, failed check.Result, timeout time.Duration) check.Result {
+	delay := config.Duration("CONFIRMATION_DELAY", time.Second)
+	for i := 1; i <= target.Confirmations; i++ {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			failed.Confirmation = &check.Confirmation{Rechecks: i - 1, Cancelled: true}
+			return failed
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		result := checker.Check(checkCtx, target)
+		cancel()
+		if ctx.Err() != nil {
+			failed.Confirmation = &check.Confirmation{Rechecks: i - 1, Cancelled: true}
+			return failed
+		}
+		if result.Status != "down" {
+			result.Confirmation = &check.Confirmation{Rechecks: i}
+			return result
+		}
+	}
+	failed.Confirmation = &check.Confirmation{Rechecks: target.Confirmations, Confirmed: true}
+	return failed
+}
+
+// diagnose traces the path to target when result failed to connect and
+// TRACEROUTE_ON_FAILURE or target.Traceroute asks for it. Guarded checks
+// never trace internal hosts and get a path without internal hops.
+func diagnose(ctx context.Context, target check.Target, result check.Result) *traceroute.Report {
+	enabled := config.String("TRACEROUTE_ON_FAILURE", "false") == "true"
+	if target.Traceroute != nil {
+		enabled = *target.Traceroute
+	}
+	if !enabled || result.Status != outcome.Down {
+		return nil
+	}
+	switch result.FailureReason {
+	case outcome.ReasonTimeout, outcome.ReasonConnectionRefused, outcome.ReasonConnection:
+	default:
+		return nil
+	}
+
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Hostname() == "" {
+		return nil
+	}
+	if err := check.AllowedHost(ctx, u.Hostname()); err != nil {
+		return nil
+	}
+	report := traceroute.Trace(ctx, u.Hostname(), target.IPVersion == check.IPv6)
+	if check.Guarded(ctx) {
+		redact(&report)
+	}
+	return &report
+}
+
+// redact strips internal addresses from report. A destination that
+// resolved to one after AllowedHost passed loses its whole path.
+func redact(report *traceroute.Report) {
+	if addr, err := netip.ParseAddr(report.Address); err == nil && check.Blocked(addr) {
+		*report = traceroute.Report{Host: report.Host, Error: outcome.ErrBlockedAddress.Error()}
+		return
+	}
+	for i, hop := range report.Hops {
+		if addr, err := netip.ParseAddr(hop.Address); err == nil && check.Blocked(addr) {
+			report.Hops[i].Address = ""
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"monitor-workder/pkg/check"
+	"monitor-workder/pkg/outcome"
+	"monitor-workder/pkg/traceroute"
+)
+
+// scriptedChecker returns statuses in order. A "cancel" entry cancels the
+// batch while the re-check runs and reports the aborted check as down.
+type scriptedChecker struct {
+	statuses []string
+	cancel   context.CancelFunc
+	timeouts []time.Duration
+}
+
+func (c *scriptedChecker) Check(ctx context.Context, target check.Target) check.Result {
+	if deadline, ok := ctx.Deadline(); ok {
+		c.timeouts = append(c.timeouts, time.Until(deadline))
+	}
+	status := c.statuses[0]
+	c.statuses = c.statuses[1:]
+	if status == "cancel" {
+		c.cancel()
+		status = "down"
+	}
+	return check.Result{Status: status}
+}
+
+func TestConfirm(t *testing.T) {
+	t.Setenv("CONFIRMATION_DELAY", "1ms")
+
+	tests := []struct {
+		name     string
+		rechecks []string
+		cancel   bool
+		want     check.Confirmation
+		status   string
+	}{
+		{name: "every re-check fails", rechecks: []string{"down", "down"}, want: check.Confirmation{Rechecks: 2, Confirmed: true}, status: "down"},
+		{name: "re-check passes", rechecks: []string{"down", "up"}, want: check.Confirmation{Rechecks: 2}, status: "up"},
+		{name: "cancelled before re-checking", cancel: true, want: check.Confirmation{Cancelled: true}, status: "down"},
+		{name: "cancelled during the last re-check", rechecks: []string{"down", "cancel"}, want: check.Confirmation{Rechecks: 1, Cancelled: true}, status: "down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				t.Setenv("CONFIRMATION_DELAY", "1h")
+				cancel()
+			}
+
+			checker := &scriptedChecker{statuses: tt.rechecks, cancel: cancel}
+			got := confirm(ctx, checker, check.Target{Confirmations: 2}, check.Result{Status: "down"}, time.Minute)
+			if got.Status != tt.status || got.Confirmation == nil || *got.Confirmation != tt.want {
+				t.Errorf("confirm() = %s %+v, want %s %+v", got.Status, got.Confirmation, tt.status, tt.want)
+			}
+			for i, timeout := range checker.timeouts {
+				if timeout < 59*time.Second {
+					t.Errorf("re-check %d had %v left, want its own full timeout", i+1, timeout)
+				}
+			}
+		})
+	}
+}
+
+func TestDiagnoseGuarded(t *testing.T) {
+	t.Setenv("TRACEROUTE_ON_FAILURE", "true")
+	ctx := check.WithGuard(context.Background())
+	failed := check.Result{Status: "down", FailureReason: outcome.ReasonTimeout}
+
+	if path := diagnose(ctx, check.Target{URL: "http://127.0.0.1:8080/"}, failed); path != nil {
+		t.Errorf("diagnose() traced an internal host for a guarded check: %+v", path)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	report := traceroute.Report{
+		Host:    "example.com",
+		Address: "93.184.216.34",
+		Reached: true,
+		Hops: []traceroute.Hop{
+			{TTL: 1, Address: "10.0.0.1"},
+			{TTL: 2, Address: "100.64.3.1"},
+			{TTL: 3, Address: "203.0.113.9"},
+			{TTL: 4, Address: "93.184.216.34"},
+		},
+	}
+	redact(&report)
+	for i, want := range []string{"", "", "203.0.113.9", "93.184.216.34"} {
+		if got := report.Hops[i].Address; got != want {
+			t.Errorf("hop %d address = %q, want %q", i+1, got, want)
+		}
+	}
+
+	internal := traceroute.Report{Host: "rebound.example", Address: "169.254.169.254", Reached: true,
+		Hops: []traceroute.Hop{{TTL: 1, Address: "169.254.169.254"}}}
+	redact(&internal)
+	if internal.Address != "" || internal.Hops != nil || internal.Error == "" {
+		t.Errorf("redact() of an internal destination = %+v, want only an error", internal)
+	}
+}
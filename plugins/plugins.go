@@ -0,0 +1,21 @@
+// Package plugins links the worker's extensions into a binary. Entry points
+// blank-import it; every extension registers itself from its init function.
+//
+// Forks add proprietary modules without touching core files by dropping a
+// file into this directory that imports their package behind a build tag:
+//
+//	//go:build acme
+//
+//	package plugins
+//
+//	import _ "example.com/acme/uptiq-pagerduty"
+//
+// and building with -tags acme.
+package plugins
+
+import (
+	_ "monitor-workder/pkg/browser"
+	_ "monitor-workder/pkg/heartbeat"
+	_ "monitor-workder/pkg/incident"
+	_ "monitor-workder/pkg/script"
+)